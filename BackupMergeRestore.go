@@ -5,10 +5,16 @@
 package main
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
 	"io"
+	"reflect"
 	"sort"
+	"strconv"
+
+	"HashMaster3000/cryptnos"
+	"HashMaster3000/derive"
+	"HashMaster3000/schema"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -24,36 +30,124 @@ type MergeState struct {
 	abortMerge     bool
 }
 
-// Backup settings to file
+// Backup settings to file, as an HM3k backup or a Cryptnos export
 func (hg *HashGenerator) backupSettings() {
 	if len(hg.savedSettings) == 0 {
 		dialog.ShowInformation("No Settings", "No settings to backup.", hg.window)
 		return
 	}
 
-	dialog.ShowFileSave(func(writer fyne.URIWriteCloser, err error) {
+	var d dialog.Dialog
+	backupButton := widget.NewButton("HM3k Backup", func() {
+		d.Hide()
+		hg.saveBackupFile("HM3k-backup.json", hg.writeBackup)
+	})
+	cryptnosButton := widget.NewButton("Export for Cryptnos", func() {
+		d.Hide()
+		hg.saveBackupFile("HM3k"+cryptnos.FileExtension, hg.exportCryptnos)
+	})
+	d = dialog.NewCustom("Backup", "Cancel", container.NewVBox(backupButton, cryptnosButton), hg.window)
+	d.Show()
+}
+
+// Ask where to save a backup, then write it there
+func (hg *HashGenerator) saveBackupFile(fileName string, write func(fyne.URIWriteCloser)) {
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
 		if err != nil || writer == nil {
 			if err != nil {
 				dialog.ShowError(fmt.Errorf("backup failed: %v", err), hg.window)
 			}
 			return
 		}
-		defer writer.Close()
+		write(writer)
+	}, hg.window)
+	saveDialog.SetFileName(fileName)
+	saveDialog.Show()
+}
 
-		data, err := json.MarshalIndent(hg.savedSettings, "", "  ")
-		if err != nil {
-			dialog.ShowError(fmt.Errorf("error encoding settings: %v", err), hg.window)
-			return
-		}
+// Write HM3k's own JSON backup
+func (hg *HashGenerator) writeBackup(writer fyne.URIWriteCloser) {
+	defer writer.Close()
 
-		_, err = writer.Write(data)
-		if err != nil {
-			dialog.ShowError(fmt.Errorf("error writing backup file: %v", err), hg.window)
-			return
+	data, err := schema.MarshalIndent(hg.savedSettings, "", "  ")
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("error encoding settings: %v", err), hg.window)
+		return
+	}
+
+	_, err = writer.Write(data)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("error writing backup file: %v", err), hg.window)
+		return
+	}
+
+	dialog.ShowInformation("Backup Complete", "Settings backed up successfully!", hg.window)
+}
+
+// Write an encrypted Cryptnos export, then report any settings Cryptnos can't represent,
+// and any that need a different master in Cryptnos
+func (hg *HashGenerator) exportCryptnos(writer fyne.URIWriteCloser) {
+	passphraseEntry := widget.NewPasswordEntry()
+	confirmEntry := widget.NewPasswordEntry()
+	confirmEntry.Validator = func(text string) error {
+		if text != passphraseEntry.Text {
+			return fmt.Errorf("passphrases don't match")
 		}
+		return nil
+	}
 
-		dialog.ShowInformation("Backup Complete", "Settings backed up successfully!", hg.window)
-	}, hg.window)
+	dialog.ShowForm("Cryptnos Export", "Export", "Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("Passphrase", passphraseEntry),
+			widget.NewFormItem("Confirm", confirmEntry),
+		},
+		func(confirmed bool) {
+			defer writer.Close()
+			if !confirmed {
+				return
+			}
+
+			exportReport, err := cryptnos.Export(writer, hg.savedSettings, passphraseEntry.Text)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("error writing Cryptnos export: %v", err), hg.window)
+				return
+			}
+
+			if len(exportReport.Rejected) == 0 && len(exportReport.Identities) == 0 {
+				dialog.ShowInformation("Export Complete",
+					fmt.Sprintf("Successfully exported %d settings!", exportReport.Exported), hg.window)
+				return
+			}
+
+			report := container.NewVBox()
+			addLine := func(text string, style fyne.TextStyle) {
+				line := widget.NewLabelWithStyle(text, fyne.TextAlignLeading, style)
+				line.Wrapping = fyne.TextWrapWord
+				report.Add(line)
+			}
+			if len(exportReport.Rejected) > 0 {
+				addLine(fmt.Sprintf("These %d can't be represented in Cryptnos, and were left out:", len(exportReport.Rejected)), fyne.TextStyle{Bold: true})
+				for _, r := range exportReport.Rejected {
+					addLine(fmt.Sprintf("%s: %s", r.Description, r.Reason), fyne.TextStyle{})
+				}
+			}
+			if len(exportReport.Identities) > 0 {
+				addLine(fmt.Sprintf("These %d were exported, but Cryptnos needs their identity's master to generate the same passwords:",
+					len(exportReport.Identities)), fyne.TextStyle{Bold: true})
+				for _, n := range exportReport.Identities {
+					addLine(fmt.Sprintf("%s: %s", n.Description, identityLabel(n.Identity)), fyne.TextStyle{})
+				}
+			}
+			title := "Export Complete"
+			if len(exportReport.Rejected) > 0 {
+				title = "Export Incomplete"
+			}
+			scroll := container.NewVScroll(report)
+			scroll.SetMinSize(fyne.NewSize(350, 300))
+			dialog.ShowCustom(title, "OK", container.NewBorder(
+				widget.NewLabel(fmt.Sprintf("Exported %d settings.", exportReport.Exported)),
+				nil, nil, nil, scroll), hg.window)
+		}, hg.window)
 }
 
 // Restore settings from file
@@ -65,37 +159,25 @@ func (hg *HashGenerator) restoreSettings() {
 			}
 			return
 		}
-		defer reader.Close()
-
-		// Read the file's contents
-		data, err := io.ReadAll(reader)
-		if err != nil {
-			dialog.ShowError(fmt.Errorf("error reading backup file: %v", err), hg.window)
-			return
-		}
 
-		// Parse the settings
-		var restoredSettings map[string]SavedSetting
-		err = json.Unmarshal(data, &restoredSettings)
-		if err != nil {
-			dialog.ShowError(fmt.Errorf("error parsing backup file: %v", err), hg.window)
-			return
-		}
-
-		// Confirm restore operation
-		dialog.ShowConfirm("Restore Settings",
-			fmt.Sprintf("This will replace your current %d settings with %d settings from the backup file. Continue?",
-				len(hg.savedSettings), len(restoredSettings)),
-			func(confirmed bool) {
-				if confirmed {
-					hg.savedSettings = restoredSettings
-					hg.saveSettingsToPreferences()
-					hg.updateFilteredKeys(hg.filterEntry.Text)
-					hg.settingsList.Refresh()
-					dialog.ShowInformation("Restore Complete",
-						fmt.Sprintf("Successfully restored %d settings!", len(restoredSettings)), hg.window)
-				}
-			}, hg.window)
+		hg.readSettingsFile(reader, func(restoredSettings map[string]SavedSetting) {
+			// Confirm restore operation
+			dialog.ShowConfirm("Restore Settings",
+				fmt.Sprintf("This will replace your current %d settings with %d settings from the backup file. Continue?",
+					len(hg.savedSettings), len(restoredSettings)),
+				func(confirmed bool) {
+					if confirmed {
+						hg.savedSettings = restoredSettings
+						hg.saveSettingsToPreferences()
+						hg.collectCustomCharsets()
+						hg.collectIdentities()
+						hg.updateFilteredKeys(hg.filterEntry.Text)
+						hg.settingsList.Refresh()
+						dialog.ShowInformation("Restore Complete",
+							fmt.Sprintf("Successfully restored %d settings!", len(restoredSettings)), hg.window)
+					}
+				}, hg.window)
+		})
 	}, hg.window)
 }
 
@@ -108,24 +190,50 @@ func (hg *HashGenerator) mergeSettings() {
 			}
 			return
 		}
-		defer reader.Close()
 
-		// Read the file's contents
-		data, err := io.ReadAll(reader)
-		if err != nil {
-			dialog.ShowError(fmt.Errorf("error reading backup file: %v", err), hg.window)
-			return
-		}
+		hg.readSettingsFile(reader, func(importedSettings map[string]SavedSetting) {
+			hg.recursiveMerge(importedSettings, nil)
+		})
+	}, hg.window)
+}
+
+// Read a settings file for restore or merge. As well as HM3k's own JSON backups,
+// this accepts encrypted Cryptnos exports (anything that isn't JSON), asking for the export passphrase first.
+func (hg *HashGenerator) readSettingsFile(reader fyne.URIReadCloser, onRead func(map[string]SavedSetting)) {
+	defer reader.Close()
 
-		// Parse the settings
-		var importedSettings map[string]SavedSetting
-		err = json.Unmarshal(data, &importedSettings)
+	// Read the file's contents
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("error reading backup file: %v", err), hg.window)
+		return
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		// Parse the settings, upgrading backups from older versions
+		settings, err := schema.Unmarshal(data)
 		if err != nil {
 			dialog.ShowError(fmt.Errorf("error parsing backup file: %v", err), hg.window)
 			return
 		}
-		hg.recursiveMerge(importedSettings, nil)
-	}, hg.window)
+		onRead(settings)
+		return
+	}
+
+	passphraseEntry := widget.NewPasswordEntry()
+	dialog.ShowForm("Cryptnos Export", "Import", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Passphrase", passphraseEntry)},
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			settings, err := cryptnos.Import(bytes.NewReader(data), passphraseEntry.Text)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("error importing Cryptnos export: %v", err), hg.window)
+				return
+			}
+			onRead(settings)
+		}, hg.window)
 }
 
 func (hg *HashGenerator) recursiveMerge(importedSettings map[string]SavedSetting, m *MergeState) {
@@ -155,6 +263,8 @@ func (hg *HashGenerator) recursiveMerge(importedSettings map[string]SavedSetting
 		}
 		hg.savedSettings = m.mergedSettings
 		hg.saveSettingsToPreferences()
+		hg.collectCustomCharsets()
+		hg.collectIdentities()
 		hg.updateFilteredKeys(hg.filterEntry.Text)
 		hg.settingsList.Refresh()
 		dialog.ShowInformation("Merge Complete",
@@ -182,7 +292,7 @@ func (hg *HashGenerator) recursiveMerge(importedSettings map[string]SavedSetting
 	}
 
 	// Check if all fields match
-	if existingSetting == newSetting {
+	if reflect.DeepEqual(existingSetting, newSetting) {
 		// Duplicate, ignore
 		m.index++
 		hg.recursiveMerge(importedSettings, m)
@@ -262,10 +372,29 @@ func (hg *HashGenerator) conflictContent(existing, newSetting SavedSetting) fyne
 	}
 
 	// Add rows for each field. Algorithm and CharRestrictions don't need labels
-	addRow("", existing.Algorithm, newSetting.Algorithm)
-	addRow("", existing.CharRestrictions, newSetting.CharRestrictions)
-	addRow("Length: ", existing.Length, newSetting.Length)
-	addRow("Iterations: ", existing.Iterations, newSetting.Iterations)
+	addRow("Identity: ", identityLabel(existing.Identity), identityLabel(newSetting.Identity))
+	addRow("Scheme: ", labelFor(schemeLabels, withDefault(existing.Scheme, derive.SchemeConcatenate)), labelFor(schemeLabels, withDefault(newSetting.Scheme, derive.SchemeConcatenate)))
+	addRow("Encoding: ", labelFor(encodingLabels, withDefault(existing.Encoding, derive.EncodingBase64)), labelFor(encodingLabels, withDefault(newSetting.Encoding, derive.EncodingBase64)))
+	addRow("", labelFor(algorithmLabels, existing.Algorithm), labelFor(algorithmLabels, newSetting.Algorithm))
+	addRow("", hg.charRestLabel(existing), hg.charRestLabel(newSetting))
+	if existing.CharRestrictions == derive.RestrictCustom || newSetting.CharRestrictions == derive.RestrictCustom {
+		addRow("Allowed: ", existing.Custom.Alphabet, newSetting.Custom.Alphabet)
+		addRow("Replace others: ", existing.Custom.Replacement, newSetting.Custom.Replacement)
+	}
+	if existing.CharRestrictions == derive.RestrictPassphrase || newSetting.CharRestrictions == derive.RestrictPassphrase {
+		addRow("Separator: ", strconv.Quote(existing.Passphrase.Separator), strconv.Quote(newSetting.Passphrase.Separator))
+		addRow("Capitalise: ", strconv.FormatBool(existing.Passphrase.Capitalise), strconv.FormatBool(newSetting.Passphrase.Capitalise))
+		addRow("Add a digit: ", strconv.FormatBool(existing.Passphrase.Digit), strconv.FormatBool(newSetting.Passphrase.Digit))
+	}
+	addRow("Length: ", strconv.Itoa(existing.Length), strconv.Itoa(newSetting.Length))
+	addRow("Iterations: ", strconv.Itoa(existing.Iterations), strconv.Itoa(newSetting.Iterations))
+	addRow("Rotations: ", strconv.Itoa(existing.Counter), strconv.Itoa(newSetting.Counter))
+	addRow("History: ", fmt.Sprintf("%d previous", len(existing.History)), fmt.Sprintf("%d previous", len(newSetting.History)))
+	addRow("Rules: ", existing.Policy.String(), newSetting.Policy.String())
+	if derive.UsesMemoryParams(existing.Scheme) || derive.UsesMemoryParams(newSetting.Scheme) {
+		addRow("Memory (KiB): ", strconv.Itoa(existing.Memory), strconv.Itoa(newSetting.Memory))
+		addRow("Parallelism: ", strconv.Itoa(existing.Parallelism), strconv.Itoa(newSetting.Parallelism))
+	}
 
 	return container.NewVBox(
 		contextList,
@@ -0,0 +1,251 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"HashMaster3000/derive"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// The time budgets offered for recommending iterations
+var benchmarkBudgets = []string{"100ms", "250ms", "500ms", "1s", "2s", "5s"}
+
+const defaultBenchmarkBudget = "1s"
+
+// A combination of parameters that's timed separately
+type benchmarkCase struct {
+	label   string
+	setting SavedSetting
+}
+
+// Rates are measured per scheme and algorithm, or per memory parameters for the memory-hard schemes
+func rateKey(setting SavedSetting) string {
+	scheme := setting.Scheme
+	if scheme == "" {
+		scheme = derive.SchemeConcatenate
+	}
+	if derive.UsesMemoryParams(scheme) {
+		return fmt.Sprintf("%s/%d/%d", scheme, setting.Memory, setting.Parallelism)
+	}
+	return scheme + "/" + setting.Algorithm
+}
+
+// Rates differ between devices, and the preferences can move between them with the settings
+// (when they're portable or synced), so the rates are kept by device name
+func benchmarkDevice() string {
+	device, _ := os.Hostname()
+	return device
+}
+
+// The measured rate for a setting on this device, if it's been benchmarked
+func (hg *HashGenerator) rate(setting SavedSetting) (derive.Rate, bool) {
+	rate, known := hg.appPrefs.Rates[benchmarkDevice()][rateKey(setting)]
+	return rate, known
+}
+
+func (hg *HashGenerator) setRate(setting SavedSetting, rate derive.Rate) {
+	device := benchmarkDevice()
+	if hg.appPrefs.Rates == nil {
+		hg.appPrefs.Rates = make(map[string]map[string]derive.Rate)
+	}
+	if hg.appPrefs.Rates[device] == nil {
+		hg.appPrefs.Rates[device] = make(map[string]derive.Rate)
+	}
+	hg.appPrefs.Rates[device][rateKey(setting)] = rate
+}
+
+// Everything worth timing: each scheme with each algorithm, and the memory-hard schemes
+// with every memory setting in use. The form's current combination goes first.
+func (hg *HashGenerator) benchmarkCases() []benchmarkCase {
+	current := hg.currentSetting()
+	cases := []benchmarkCase{}
+	seen := map[string]bool{}
+	add := func(setting SavedSetting) {
+		key := rateKey(setting)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		label := labelFor(schemeLabels, setting.Scheme) + " " + labelFor(algorithmLabels, setting.Algorithm)
+		if derive.UsesMemoryParams(setting.Scheme) {
+			label = fmt.Sprintf("%s %d KiB ×%d", labelFor(schemeLabels, setting.Scheme), setting.Memory, setting.Parallelism)
+		}
+		cases = append(cases, benchmarkCase{label: label, setting: setting})
+	}
+
+	if current.Scheme == "" {
+		current.Scheme = derive.SchemeConcatenate
+	}
+	add(current)
+	for _, scheme := range derive.Schemes {
+		if derive.UsesMemoryParams(scheme) {
+			for _, setting := range hg.savedSettings {
+				if setting.Scheme == scheme {
+					add(setting)
+				}
+			}
+			continue
+		}
+		for _, algorithm := range derive.Algorithms {
+			add(SavedSetting{Scheme: scheme, Algorithm: algorithm})
+		}
+	}
+	return cases
+}
+
+// The expected time to generate a saved setting's password, once its rate has been measured
+func (hg *HashGenerator) expectedTime(setting SavedSetting) (string, bool) {
+	rate, known := hg.rate(setting)
+	if !known {
+		return "", false
+	}
+	if setting.Iterations < 1 {
+		return "", false
+	}
+	return formatDuration(rate.Estimate(setting.Iterations)), true
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return "<1ms"
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	default:
+		return d.Round(100 * time.Millisecond).String()
+	}
+}
+
+// Times every scheme and algorithm on this device, and recommends iterations for the form
+func (hg *HashGenerator) showBenchmark() {
+	budget, _ := time.ParseDuration(defaultBenchmarkBudget)
+	cases := hg.benchmarkCases()
+
+	describe := func(c benchmarkCase) string {
+		rate, known := hg.rate(c.setting)
+		switch {
+		case !known:
+			return c.label + ": -"
+		case rate.PerIteration == 0:
+			return fmt.Sprintf("%s: %s", c.label, formatDuration(rate.Fixed))
+		default:
+			return fmt.Sprintf("%s: %v per iteration, %d in %v", c.label, rate.PerIteration, rate.IterationsWithin(budget), budget)
+		}
+	}
+	results := widget.NewList(
+		func() int {
+			return len(cases)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("ListTemplateItemDummyText")
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(describe(cases[id]))
+		},
+	)
+
+	// The recommendation for whatever's on the form
+	recommendation := widget.NewLabel("")
+	recommendation.Wrapping = fyne.TextWrapWord
+	useButton := widget.NewButton("Use", nil)
+	recommended := 0
+	updateRecommendation := func() {
+		recommended = 0
+		rate, known := hg.rate(cases[0].setting)
+		switch {
+		case !known:
+			recommendation.SetText("Run the benchmark for a recommendation.")
+		case rate.PerIteration == 0:
+			recommendation.SetText(fmt.Sprintf("%s takes %s, its cost is set by Memory rather than Iterations.", cases[0].label, formatDuration(rate.Fixed)))
+		default:
+			recommended = rate.IterationsWithin(budget)
+			recommendation.SetText(fmt.Sprintf("%s: %d iterations take about %v.", cases[0].label, recommended, budget))
+		}
+		if recommended > 0 {
+			useButton.Enable()
+		} else {
+			useButton.Disable()
+		}
+	}
+	useButton.OnTapped = func() {
+		hg.iterationsEntry.SetText(strconv.Itoa(recommended))
+	}
+
+	budgetSelect := widget.NewSelect(benchmarkBudgets, func(selected string) {
+		budget, _ = time.ParseDuration(selected)
+		updateRecommendation()
+		results.Refresh()
+	})
+	budgetSelect.SetSelected(defaultBenchmarkBudget)
+
+	progress := widget.NewProgressBar()
+	progress.Hide()
+	var cancel context.CancelFunc
+	runButton := widget.NewButton("Run", nil)
+	runButton.OnTapped = func() {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		runButton.Disable()
+		progress.SetValue(0)
+		progress.Show()
+		go func() {
+			defer cancel()
+			for i, c := range cases {
+				rate, err := derive.Measure(ctx, c.setting)
+				if errors.Is(err, context.Canceled) {
+					return
+				}
+				fyne.Do(func() {
+					// Combinations that can't be used together (e.g. HMAC with SHAKE) just stay unmeasured
+					if err == nil {
+						hg.setRate(c.setting, rate)
+					}
+					progress.SetValue(float64(i+1) / float64(len(cases)))
+					results.RefreshItem(i)
+					if i == 0 {
+						updateRecommendation()
+					}
+				})
+			}
+			fyne.Do(func() {
+				progress.Hide()
+				runButton.Enable()
+				hg.saveAppPreferences()
+				hg.settingsList.Refresh()
+			})
+		}()
+	}
+
+	content := container.NewBorder(
+		container.NewVBox(
+			container.NewBorder(nil, nil, widget.NewLabel("Target time:"), runButton, budgetSelect),
+			container.NewBorder(nil, nil, nil, useButton, recommendation),
+			progress,
+		),
+		nil, nil, nil,
+		results,
+	)
+	updateRecommendation()
+
+	d := dialog.NewCustom("Benchmark", "Close", content, hg.window)
+	d.SetOnClosed(func() {
+		if cancel != nil {
+			cancel()
+		}
+	})
+	d.Resize(fyne.NewSize(hg.window.Canvas().Size().Width, hg.window.Canvas().Size().Height*0.8))
+	d.Show()
+}
@@ -8,11 +8,89 @@ import (
 	"fmt"
 	"strconv"
 
+	"HashMaster3000/derive"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 )
 
+// How the algorithms, schemes, encodings and restrictions are shown. Settings store the identifiers, so these can be reworded freely.
+var algorithmLabels = map[string]string{
+	derive.AlgorithmSHA256:    "SHA-256",
+	derive.AlgorithmSHA512:    "SHA-512",
+	derive.AlgorithmSHA1:      "SHA-1",
+	derive.AlgorithmMD5:       "MD5",
+	derive.AlgorithmSHA224:    "SHA-224",
+	derive.AlgorithmSHA384:    "SHA-384",
+	derive.AlgorithmSHA3_224:  "SHA3-224",
+	derive.AlgorithmSHA3_256:  "SHA3-256",
+	derive.AlgorithmSHA3_384:  "SHA3-384",
+	derive.AlgorithmSHA3_512:  "SHA3-512",
+	derive.AlgorithmSHAKE128:  "SHAKE128",
+	derive.AlgorithmSHAKE256:  "SHAKE256",
+	derive.AlgorithmRIPEMD160: "RIPEMD-160",
+	derive.AlgorithmWhirlpool: "Whirlpool",
+	derive.AlgorithmTiger:     "Tiger",
+}
+
+var schemeLabels = map[string]string{
+	derive.SchemeConcatenate: "Concatenate",
+	derive.SchemeHMAC:        "HMAC",
+	derive.SchemePBKDF2:      "PBKDF2",
+	derive.SchemeArgon2id:    "Argon2id",
+	derive.SchemeScrypt:      "scrypt",
+}
+
+var encodingLabels = map[string]string{
+	derive.EncodingBase64:    "Base64",
+	derive.EncodingBase64URL: "Base64 URL-safe",
+	derive.EncodingHex:       "Hex",
+	derive.EncodingBase32:    "Base32",
+	derive.EncodingBase58:    "Base58",
+	derive.EncodingZ85:       "Z85",
+}
+
+var charRestLabels = map[string]string{
+	derive.RestrictNone:            "All generated chars",
+	derive.RestrictAlnumUnderscore: "Alphanumeric (replace others with underscore)",
+	derive.RestrictAlnumOmit:       "Alphanumeric (omit others)",
+	derive.RestrictAlpha:           "Alpha only",
+	derive.RestrictNumeric:         "Numeric only",
+	derive.RestrictUniformAlnum:    "Alphanumeric (exact length)",
+	derive.RestrictUniformAlpha:    "Alpha only (exact length)",
+	derive.RestrictUniformNumeric:  "Numeric only (exact length)",
+	derive.RestrictPassphrase:      "Passphrase (words)",
+}
+
+// The label for an identifier, or the identifier itself if it hasn't got one
+func labelFor(labels map[string]string, id string) string {
+	if label, exists := labels[id]; exists {
+		return label
+	}
+	return id
+}
+
+// The identifier for a label, or the label itself if it isn't one
+func idFor(labels map[string]string, label string) string {
+	for id, l := range labels {
+		if l == label {
+			return id
+		}
+	}
+	return label
+}
+
+func labelsFor(labels map[string]string, ids []string) []string {
+	result := make([]string, len(ids))
+	for i, id := range ids {
+		result[i] = labelFor(labels, id)
+	}
+	return result
+}
+
 func (hg *HashGenerator) makeUIcomponents() {
 	// Description token entry
 	hg.descriptionEntry = widget.NewEntry()
@@ -41,6 +119,20 @@ func (hg *HashGenerator) makeUIcomponents() {
 	hg.masterPassEntry.OnSubmitted = func(_ string) {
 		hg.generateHash()
 	}
+	// A recognisable fingerprint of the master, to catch typos
+	hg.fingerprintLabel = widget.NewLabelWithStyle("", fyne.TextAlignTrailing, fyne.TextStyle{Italic: true})
+	hg.masterPassEntry.OnChanged = hg.updateFingerprint
+	hg.verifierButton = widget.NewButtonWithIcon("", theme.ConfirmIcon(), hg.editVerifiers)
+
+	// Identity selection (which master is on the form)
+	hg.identitySelect = widget.NewSelect(hg.identityLabels(), func(selected string) {
+		hg.setIdentity(identityFromLabel(selected))
+	})
+	hg.identityButton = widget.NewButtonWithIcon("", theme.AccountIcon(), hg.editIdentities)
+	hg.identity = hg.appPrefs.LastIdentity
+	hg.identitySelect.SetSelected(identityLabel(hg.identity))
+	hg.masterPassEntry.SetPlaceHolder(fmt.Sprintf("Enter master pass for %s...", identityLabel(hg.identity)))
+	hg.setVerifiers(hg.verifiers())
 	hg.masterPassEntry.Validator = func(text string) error {
 		if text == "" {
 			return fmt.Errorf("master password cannot be empty")
@@ -52,33 +144,53 @@ func (hg *HashGenerator) makeUIcomponents() {
 	hg.masterPassEntry.FocusLost()
 
 	// Algorithm selection
-	hg.algorithmSelect = widget.NewSelect([]string{
-		"SHA-256",
-		"SHA-512",
-		"SHA-1",
-		"MD5",
-		"SHA-224",
-		"SHA-384",
-	}, func(selected string) {
+	hg.algorithmSelect = widget.NewSelect(labelsFor(algorithmLabels, derive.Algorithms), func(selected string) {
+		// Save preference when changed
+		hg.appPrefs.LastAlgorithm = idFor(algorithmLabels, selected)
+		hg.saveAppPreferences()
+	})
+	hg.algorithmSelect.SetSelected(labelFor(algorithmLabels, hg.appPrefs.LastAlgorithm))
+
+	// Scheme selection (how the description and master are combined)
+	hg.schemeSelect = widget.NewSelect(labelsFor(schemeLabels, derive.Schemes), func(selected string) {
 		// Save preference when changed
-		hg.appPrefs.LastAlgorithm = selected
+		hg.appPrefs.LastScheme = idFor(schemeLabels, selected)
 		hg.saveAppPreferences()
+		hg.updateMemoryParamsEnabled()
 	})
-	hg.algorithmSelect.SetSelected(hg.appPrefs.LastAlgorithm)
-
-	// Character restriction selection
-	hg.charRestSelect = widget.NewSelect([]string{
-		"All generated chars",
-		"Alphanumeric (replace others with underscore)",
-		"Alphanumeric (omit others)",
-		"Alpha only",
-		"Numeric only",
-	}, func(selected string) {
+
+	// Output encoding selection
+	hg.encodingSelect = widget.NewSelect(labelsFor(encodingLabels, derive.Encodings), func(selected string) {
 		// Save preference when changed
-		hg.appPrefs.LastCharRest = selected
+		hg.appPrefs.LastEncoding = idFor(encodingLabels, selected)
 		hg.saveAppPreferences()
 	})
-	hg.charRestSelect.SetSelected(hg.appPrefs.LastCharRest)
+	hg.encodingSelect.SetSelected(labelFor(encodingLabels, hg.appPrefs.LastEncoding))
+
+	// Character restriction selection, with a button for the options of the restrictions that have them
+	hg.charRestButton = widget.NewButtonWithIcon("", theme.SettingsIcon(), hg.editCharRestOptions)
+	hg.lengthLabel = widget.NewLabel("Length:")
+	hg.setPassphrase(hg.appPrefs.LastPassphrase)
+	hg.charRestSelect = widget.NewSelect(nil, func(selected string) {
+		if selected == defineCustomCharsetOption {
+			hg.defineCustomCharset()
+			return
+		}
+		// Save preference when changed
+		restriction, custom := hg.charRestFromLabel(selected)
+		hg.appPrefs.LastCharRest = restriction
+		if restriction == derive.RestrictCustom {
+			hg.appPrefs.LastCustom = custom
+		}
+		hg.saveAppPreferences()
+		hg.updateCharRestOptions()
+	})
+	if hg.appPrefs.LastCustom.Name != "" {
+		hg.customCharsets[hg.appPrefs.LastCustom] = true
+	}
+	hg.charRestSelect.SetOptions(hg.charRestOptions())
+	hg.charRestSelect.SetSelected(hg.lastCharRestLabel())
+	hg.updateCharRestOptions()
 
 	// Length entry
 	hg.lengthEntry = widget.NewEntry()
@@ -103,6 +215,10 @@ func (hg *HashGenerator) makeUIcomponents() {
 	hg.lengthEntry.FocusGained()
 	hg.lengthEntry.FocusLost()
 
+	// Composition rules button (the rules themselves are edited in a dialog)
+	hg.policyButton = widget.NewButton("Rules", hg.editPolicy)
+	hg.setPolicy(hg.appPrefs.LastPolicy)
+
 	// Iterations entry
 	hg.iterationsEntry = widget.NewEntry()
 	hg.iterationsEntry.SetPlaceHolder("Num hashes")
@@ -122,11 +238,57 @@ func (hg *HashGenerator) makeUIcomponents() {
 	// Force validation on startup
 	hg.iterationsEntry.FocusGained()
 	hg.iterationsEntry.FocusLost()
+	hg.benchmarkButton = widget.NewButtonWithIcon("", theme.HistoryIcon(), hg.showBenchmark)
+
+	// Memory and parallelism entries, only used by the memory-hard schemes
+	hg.memoryEntry = widget.NewEntry()
+	hg.memoryEntry.SetPlaceHolder("KiB")
+	hg.memoryEntry.SetText(hg.appPrefs.LastMemory)
+	hg.memoryEntry.OnChanged = func(text string) {
+		// Save preference when changed
+		hg.appPrefs.LastMemory = text
+		hg.saveAppPreferences()
+	}
+	hg.memoryEntry.Validator = func(text string) error {
+		if num, err := strconv.Atoi(text); err != nil || num < 1 || num > derive.MaxMemory {
+			return fmt.Errorf("memory must be between 1 and %d KiB", derive.MaxMemory)
+		}
+		return nil
+	}
+	hg.parallelismEntry = widget.NewEntry()
+	hg.parallelismEntry.SetPlaceHolder("Threads")
+	hg.parallelismEntry.SetText(hg.appPrefs.LastParallelism)
+	hg.parallelismEntry.OnChanged = func(text string) {
+		// Save preference when changed
+		hg.appPrefs.LastParallelism = text
+		hg.saveAppPreferences()
+	}
+	hg.parallelismEntry.Validator = func(text string) error {
+		if num, err := strconv.Atoi(text); err != nil || num < 1 || num > derive.MaxParallelism {
+			return fmt.Errorf("parallelism must be between 1 and %d", derive.MaxParallelism)
+		}
+		return nil
+	}
+	// Setting the scheme now that the entries exist will enable/disable them to suit
+	hg.schemeSelect.SetSelected(labelFor(schemeLabels, hg.appPrefs.LastScheme))
+	hg.updateMemoryParamsEnabled()
 
 	// Generate button
 	hg.genButton = widget.NewButton("Generate", hg.generateHash)
 	hg.genButton.Importance = widget.HighImportance
 
+	// Progress of slow derivations, hidden unless one is running
+	hg.progressBar = widget.NewProgressBar()
+	hg.progressBusy = widget.NewProgressBarInfinite()
+	hg.progressBusy.Stop()
+	hg.cancelButton = widget.NewButtonWithIcon("", theme.CancelIcon(), func() {
+		if hg.cancelDerive != nil {
+			hg.cancelDerive()
+		}
+	})
+	hg.progressRow = container.NewBorder(nil, nil, nil, hg.cancelButton, container.NewStack(hg.progressBar, hg.progressBusy))
+	hg.progressRow.Hide()
+
 	// Output entry
 	hg.outputEntry = widget.NewPasswordEntry()
 	hg.outputEntry.SetPlaceHolder("Hash will appear here...")
@@ -136,6 +298,14 @@ func (hg *HashGenerator) makeUIcomponents() {
 	hg.filterEntry = widget.NewEntry()
 	hg.filterEntry.SetText(hg.appPrefs.LastFilter)
 	hg.filterEntry.SetPlaceHolder("Filter settings...")
+	hg.identityFilter = widget.NewSelect(append([]string{allIdentitiesLabel}, hg.identityLabels()...), func(selected string) {
+		hg.appPrefs.FilterIdentity = selected
+		hg.saveAppPreferences()
+		if hg.settingsList != nil {
+			hg.filterSettings(hg.filterEntry.Text)
+		}
+	})
+	hg.identityFilter.SetSelected(withDefault(hg.appPrefs.FilterIdentity, allIdentitiesLabel))
 	hg.filterEntry.OnChanged = func(text string) {
 		hg.appPrefs.LastFilter = text
 		hg.saveAppPreferences()
@@ -146,6 +316,13 @@ func (hg *HashGenerator) makeUIcomponents() {
 	hg.backupButton = widget.NewButton("Backup", hg.backupSettings)
 	hg.mergeButton = widget.NewButton("Merge", hg.mergeSettings)
 	hg.restoreButton = widget.NewButton("Restore", hg.restoreSettings)
+	hg.vaultButton = widget.NewButton("Encrypt", hg.editVault)
+	hg.updateVaultButton()
+	hg.storeButton = widget.NewButtonWithIcon("", theme.StorageIcon(), hg.editStore)
+	if hg.portable {
+		// The store is wherever the executable is
+		hg.storeButton.Disable()
+	}
 
 	// Initialize filtered keys
 	hg.updateFilteredKeys("")
@@ -166,7 +343,11 @@ func (hg *HashGenerator) makeUIcomponents() {
 			setting := hg.savedSettings[key]
 			label := obj.(*ClickableLabel)
 
-			label.SetText(setting.Description)
+			if expected, known := hg.expectedTime(setting); known {
+				label.SetText(setting.Description + " (" + expected + ")")
+			} else {
+				label.SetText(setting.Description)
+			}
 			label.OnTapped = func() {
 				hg.settingsList.Select(id)
 				hg.loadSetting(key)
@@ -175,10 +356,18 @@ func (hg *HashGenerator) makeUIcomponents() {
 				hg.settingsList.Select(id)
 				hg.loadSetting(key)
 				menu := fyne.NewMenu("",
-					fyne.NewMenuItem("Delete", func() {
-						hg.deleteSetting(key)
+					fyne.NewMenuItem("Rotate Password", func() {
+						hg.rotateSetting(key)
 					}),
 				)
+				if len(setting.History) > 0 {
+					menu.Items = append(menu.Items, fyne.NewMenuItem("Old && New Passwords", func() {
+						hg.showPasswordChange(key)
+					}))
+				}
+				menu.Items = append(menu.Items, fyne.NewMenuItemSeparator(), fyne.NewMenuItem("Delete", func() {
+					hg.deleteSetting(key)
+				}))
 				widget.ShowPopUpMenuAtPosition(menu, hg.window.Canvas(), pos.AbsolutePosition)
 			}
 		},
@@ -200,29 +389,114 @@ func (hg *HashGenerator) makeUIcomponents() {
 	hg.copyToClipboard.SetChecked(hg.appPrefs.CopyToClipboard)
 }
 
+// The memory and parallelism entries are only relevant to Argon2id and scrypt
+func (hg *HashGenerator) updateMemoryParamsEnabled() {
+	if derive.UsesMemoryParams(idFor(schemeLabels, hg.schemeSelect.Selected)) {
+		hg.memoryEntry.Enable()
+		hg.parallelismEntry.Enable()
+	} else {
+		hg.memoryEntry.Disable()
+		hg.parallelismEntry.Disable()
+	}
+}
+
+// Set the composition rules, and show whether there are any on the button
+func (hg *HashGenerator) setPolicy(policy derive.Policy) {
+	hg.policy = policy
+	hg.appPrefs.LastPolicy = policy
+	hg.saveAppPreferences()
+	if policy.Active() {
+		hg.policyButton.Importance = widget.HighImportance
+	} else {
+		hg.policyButton.Importance = widget.MediumImportance
+	}
+	hg.policyButton.Refresh()
+}
+
+// Edit the minimum number of each character class, and which symbols are allowed
+func (hg *HashGenerator) editPolicy() {
+	countEntry := func(value int) *widget.Entry {
+		entry := widget.NewEntry()
+		entry.SetText(strconv.Itoa(value))
+		entry.Validator = func(text string) error {
+			if num, err := strconv.Atoi(text); err != nil || num < 0 {
+				return fmt.Errorf("must be a non-negative integer")
+			}
+			return nil
+		}
+		return entry
+	}
+	upperEntry := countEntry(hg.policy.MinUpper)
+	lowerEntry := countEntry(hg.policy.MinLower)
+	digitEntry := countEntry(hg.policy.MinDigit)
+	symbolEntry := countEntry(hg.policy.MinSymbol)
+	symbolsEntry := widget.NewEntry()
+	symbolsEntry.SetPlaceHolder("Any")
+	symbolsEntry.SetText(hg.policy.Symbols)
+
+	dialog.ShowForm("Composition Rules", "OK", "Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("Min upper", upperEntry),
+			widget.NewFormItem("Min lower", lowerEntry),
+			widget.NewFormItem("Min digits", digitEntry),
+			widget.NewFormItem("Min symbols", symbolEntry),
+			widget.NewFormItem("Symbols", symbolsEntry),
+		},
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			// The validators have already run, so the counts parse
+			atoi := func(text string) int {
+				num, _ := strconv.Atoi(text)
+				return num
+			}
+			hg.setPolicy(derive.Policy{
+				MinUpper:  atoi(upperEntry.Text),
+				MinLower:  atoi(lowerEntry.Text),
+				MinDigit:  atoi(digitEntry.Text),
+				MinSymbol: atoi(symbolEntry.Text),
+				Symbols:   symbolsEntry.Text,
+			})
+		}, hg.window)
+}
+
 func (hg *HashGenerator) layoutUI() fyne.CanvasObject {
 	// Create main form elements with labels
 	form := container.NewVBox(
 		container.NewBorder(nil, nil, widget.NewLabel("Description:"), nil, hg.descriptionEntry),
-		container.NewBorder(nil, nil, widget.NewLabel("Master Pass:"), nil, hg.masterPassEntry),
+		container.NewBorder(nil, nil, widget.NewLabel("Identity:"), hg.identityButton, hg.identitySelect),
+		container.NewBorder(nil, nil, widget.NewLabel("Master Pass:"), container.NewHBox(hg.fingerprintLabel, hg.verifierButton), hg.masterPassEntry),
+		container.NewGridWithColumns(2,
+			hg.schemeSelect,
+			hg.encodingSelect,
+		),
 		container.NewGridWithColumns(2,
 			hg.algorithmSelect,
-			container.NewBorder(nil, nil, widget.NewLabel("Iterations:"), nil, hg.iterationsEntry),
+			container.NewBorder(nil, nil, widget.NewLabel("Iterations:"), hg.benchmarkButton, hg.iterationsEntry),
+		),
+		container.NewGridWithColumns(2,
+			container.NewBorder(nil, nil, nil, hg.charRestButton, hg.charRestSelect),
+			container.NewBorder(nil, nil, hg.lengthLabel, hg.policyButton, hg.lengthEntry),
 		),
 		container.NewGridWithColumns(2,
-			hg.charRestSelect,
-			container.NewBorder(nil, nil, widget.NewLabel("Length:"), nil, hg.lengthEntry),
+			container.NewBorder(nil, nil, widget.NewLabel("Memory:"), nil, hg.memoryEntry),
+			container.NewBorder(nil, nil, widget.NewLabel("Parallelism:"), nil, hg.parallelismEntry),
 		),
 		widget.NewSeparator(),
 		container.NewBorder(nil, nil, nil, hg.copyToClipboard, hg.genButton),
+		hg.progressRow,
 		container.NewThemeOverride(hg.outputEntry, NewHashTheme(1.6)),
 	)
 
 	// Create backup/restore buttons container
-	backupRestoreContainer := container.NewGridWithColumns(3,
-		hg.backupButton,
-		hg.mergeButton,
-		hg.restoreButton,
+	backupRestoreContainer := container.NewBorder(nil, nil, nil, hg.storeButton,
+		container.NewGridWithColumns(4,
+			hg.backupButton,
+			hg.mergeButton,
+			hg.restoreButton,
+			hg.vaultButton,
+		),
 	)
 
 	// Create panel for saved settings
@@ -230,7 +504,7 @@ func (hg *HashGenerator) layoutUI() fyne.CanvasObject {
 		container.NewVBox(
 			widget.NewSeparator(),
 			widget.NewLabelWithStyle("Saved Settings", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
-			container.NewBorder(nil, nil, nil, hg.hideZeroIterBox,
+			container.NewBorder(nil, nil, nil, container.NewHBox(hg.identityFilter, hg.hideZeroIterBox),
 				hg.filterEntry,
 			),
 		),
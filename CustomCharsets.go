@@ -0,0 +1,160 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"HashMaster3000/derive"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Custom restrictions are listed after the built-ins, under this prefix
+const customCharsetPrefix = "Custom: "
+
+// The last restriction option opens a dialog to define a custom one
+const defineCustomCharsetOption = "Define custom..."
+
+// The restriction options: the built-ins, every known custom charset, then the option to define a new one
+func (hg *HashGenerator) charRestOptions() []string {
+	labels := make([]string, 0, len(hg.customCharsets))
+	for custom := range hg.customCharsets {
+		labels = append(labels, hg.customCharsetLabel(custom))
+	}
+	sort.Strings(labels)
+
+	options := labelsFor(charRestLabels, derive.CharRestrictions)
+	options = append(options, labels...)
+	return append(options, defineCustomCharsetOption)
+}
+
+// How a custom charset is listed. Saved settings can define different charsets under the same name,
+// so those are told apart by what they allow.
+func (hg *HashGenerator) customCharsetLabel(custom derive.CustomCharset) string {
+	label := customCharsetPrefix + custom.Name
+	for other := range hg.customCharsets {
+		if other.Name == custom.Name && other != custom {
+			if custom.Replacement == "" {
+				return fmt.Sprintf("%s [%s]", label, custom.Alphabet)
+			}
+			return fmt.Sprintf("%s [%s, others %s]", label, custom.Alphabet, custom.Replacement)
+		}
+	}
+	return label
+}
+
+// Remember a custom charset and make it selectable. Adding one can change the labels of others with
+// the same name, so whatever was selected is selected again under its new label.
+func (hg *HashGenerator) addCustomCharset(custom derive.CustomCharset) {
+	hg.addCustomCharsets([]derive.CustomCharset{custom})
+}
+
+func (hg *HashGenerator) addCustomCharsets(customs []derive.CustomCharset) {
+	restriction, selected := hg.charRestFromLabel(hg.charRestSelect.Selected)
+	for _, custom := range customs {
+		hg.customCharsets[custom] = true
+	}
+	hg.charRestSelect.SetOptions(hg.charRestOptions())
+	if restriction == derive.RestrictCustom {
+		hg.charRestSelect.SetSelected(hg.customCharsetLabel(selected))
+	}
+}
+
+// Make the custom charsets defined in the saved settings selectable
+func (hg *HashGenerator) collectCustomCharsets() {
+	customs := []derive.CustomCharset{}
+	for _, setting := range hg.savedSettings {
+		if setting.CharRestrictions == derive.RestrictCustom {
+			customs = append(customs, setting.Custom)
+		}
+	}
+	hg.addCustomCharsets(customs)
+}
+
+// How a setting's restriction is shown in the restriction select
+func (hg *HashGenerator) charRestLabel(setting SavedSetting) string {
+	if setting.CharRestrictions == derive.RestrictCustom {
+		return hg.customCharsetLabel(setting.Custom)
+	}
+	return labelFor(charRestLabels, setting.CharRestrictions)
+}
+
+// The label of the restriction that was last selected
+func (hg *HashGenerator) lastCharRestLabel() string {
+	return hg.charRestLabel(SavedSetting{CharRestrictions: hg.appPrefs.LastCharRest, Custom: hg.appPrefs.LastCustom})
+}
+
+// The restriction (and custom charset definition, if any) for a restriction select option
+func (hg *HashGenerator) charRestFromLabel(label string) (string, derive.CustomCharset) {
+	if strings.HasPrefix(label, customCharsetPrefix) {
+		for custom := range hg.customCharsets {
+			if hg.customCharsetLabel(custom) == label {
+				return derive.RestrictCustom, custom
+			}
+		}
+		return derive.RestrictCustom, derive.CustomCharset{}
+	}
+	return idFor(charRestLabels, label), derive.CustomCharset{}
+}
+
+// Define (or redefine) a custom charset, starting from the previously selected one if it was custom
+func (hg *HashGenerator) defineCustomCharset() {
+	previous := derive.CustomCharset{}
+	if hg.appPrefs.LastCharRest == derive.RestrictCustom {
+		previous = hg.appPrefs.LastCustom
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(previous.Name)
+	nameEntry.Validator = func(text string) error {
+		if text == "" {
+			return fmt.Errorf("name cannot be empty")
+		}
+		return nil
+	}
+	alphabetEntry := widget.NewEntry()
+	alphabetEntry.SetText(previous.Alphabet)
+	alphabetEntry.Validator = func(text string) error {
+		if text == "" {
+			return fmt.Errorf("alphabet cannot be empty")
+		}
+		return nil
+	}
+	replacementEntry := widget.NewEntry()
+	replacementEntry.SetPlaceHolder("Omit")
+	replacementEntry.SetText(previous.Replacement)
+	replacementEntry.Validator = func(text string) error {
+		if utf8.RuneCountInString(text) > 1 {
+			return fmt.Errorf("replacement must be a single character")
+		}
+		return nil
+	}
+
+	dialog.ShowForm("Custom Restriction", "OK", "Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("Name", nameEntry),
+			widget.NewFormItem("Allowed", alphabetEntry),
+			widget.NewFormItem("Replace others", replacementEntry),
+		},
+		func(confirmed bool) {
+			if !confirmed {
+				// Go back to whatever was selected before
+				hg.charRestSelect.SetSelected(hg.lastCharRestLabel())
+				return
+			}
+			custom := derive.CustomCharset{
+				Name:        nameEntry.Text,
+				Alphabet:    alphabetEntry.Text,
+				Replacement: replacementEntry.Text,
+			}
+			hg.addCustomCharset(custom)
+			hg.charRestSelect.SetSelected(hg.customCharsetLabel(custom))
+		}, hg.window)
+}
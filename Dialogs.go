@@ -0,0 +1,63 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"HashMaster3000/vault"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Show a dialog across most of the window, so entries and wrapped text have room
+func (hg *HashGenerator) showWide(d dialog.Dialog) {
+	d.Resize(fyne.NewSize(hg.window.Canvas().Size().Width*0.9, d.MinSize().Height))
+	d.Show()
+}
+
+// Nothing can be loaded or saved after some errors, so the only way out is to quit
+func (hg *HashGenerator) quitWithError(err error) {
+	errorDialog := dialog.NewError(err, hg.window)
+	errorDialog.SetOnClosed(hg.app.Quit)
+	errorDialog.Show()
+}
+
+// Ask for the passphrase that unlocks the settings, before they're loaded. Deriving the key is deliberately slow,
+// so unlock runs in the background, and returns what to do with what it unlocked back on the UI thread.
+// A wrong passphrase asks again. There's nothing to do without the settings, so any other error, or cancelling, quits.
+func (hg *HashGenerator) askUnlockPassphrase(unlock func(passphrase string) (func(), error)) {
+	passphraseEntry := widget.NewPasswordEntry()
+	d := dialog.NewForm("Unlock Settings", "Unlock", "Quit",
+		[]*widget.FormItem{widget.NewFormItem("Passphrase", passphraseEntry)},
+		func(confirmed bool) {
+			if !confirmed {
+				hg.app.Quit()
+				return
+			}
+			passphrase := passphraseEntry.Text
+			go func() {
+				unlocked, err := unlock(passphrase)
+				fyne.Do(func() {
+					if errors.Is(err, vault.ErrDecrypt) {
+						errorDialog := dialog.NewError(err, hg.window)
+						errorDialog.SetOnClosed(func() { hg.askUnlockPassphrase(unlock) })
+						errorDialog.Show()
+						return
+					}
+					if err != nil {
+						hg.quitWithError(fmt.Errorf("error opening settings: %v", err))
+						return
+					}
+					unlocked()
+				})
+			}()
+		}, hg.window)
+	hg.showWide(d)
+	hg.window.Canvas().Focus(passphraseEntry)
+}
@@ -0,0 +1,228 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"HashMaster3000/schema"
+	"HashMaster3000/vault"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// What's kept in the vault: the settings, and the preferences that would give away which accounts there are
+type vaultPayload struct {
+	Settings        json.RawMessage `json:"settings"` // a schema document
+	LastDescription string          `json:"last_description"`
+	LastFilter      string          `json:"last_filter"`
+}
+
+// Reports whether the settings are stored encrypted, whether or not the vault is unlocked yet
+func (hg *HashGenerator) encrypted() bool {
+	return hg.store.Get(vaultDocument) != ""
+}
+
+// Show whether the settings are encrypted on the button
+func (hg *HashGenerator) updateVaultButton() {
+	hg.vaultButton.Enable()
+	if hg.storeConfig.Encrypted() && !hg.encrypted() {
+		// The whole store is already encrypted
+		hg.vaultButton.SetText("Encrypted")
+		hg.vaultButton.Importance = widget.HighImportance
+		hg.vaultButton.Disable()
+	} else if hg.encrypted() {
+		hg.vaultButton.SetText("Encrypted")
+		hg.vaultButton.Importance = widget.HighImportance
+	} else {
+		hg.vaultButton.SetText("Encrypt")
+		hg.vaultButton.Importance = widget.MediumImportance
+	}
+	hg.vaultButton.Refresh()
+}
+
+// Seal the settings into the vault. Nothing is saved until the vault is unlocked,
+// so settings that were never loaded can't overwrite the ones in it.
+func (hg *HashGenerator) saveVault() {
+	if hg.vault == nil || hg.readOnly != nil {
+		return
+	}
+	settings, err := schema.Marshal(hg.savedSettings)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("error encoding settings: %v", err), hg.window)
+		return
+	}
+	payload, err := json.Marshal(vaultPayload{
+		Settings:        settings,
+		LastDescription: hg.appPrefs.LastDescription,
+		LastFilter:      hg.appPrefs.LastFilter,
+	})
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("error encoding settings: %v", err), hg.window)
+		return
+	}
+	sealed, err := hg.vault.Seal(payload)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("error encrypting settings: %v", err), hg.window)
+		return
+	}
+	if err = hg.store.Set(vaultDocument, string(sealed)); err != nil {
+		dialog.ShowError(fmt.Errorf("error saving settings: %v", err), hg.window)
+	}
+}
+
+// Ask for the vault passphrase before the settings are loaded
+func (hg *HashGenerator) unlockVault() {
+	sealed := hg.store.Get(vaultDocument)
+	hg.askUnlockPassphrase(func(passphrase string) (func(), error) {
+		unlocked, payload, err := vault.Unlock([]byte(sealed), passphrase)
+		return func() { hg.openVault(unlocked, payload) }, err
+	})
+}
+
+func (hg *HashGenerator) openVault(unlocked *vault.Vault, payload []byte) {
+	var contents vaultPayload
+	err := json.Unmarshal(payload, &contents)
+	if err == nil {
+		hg.savedSettings, err = schema.Unmarshal(contents.Settings)
+	}
+	if err != nil {
+		hg.markReadOnly(fmt.Errorf("error parsing saved settings: %v", err))
+		hg.savedSettings = make(map[string]SavedSetting)
+		hg.settingsLoaded()
+		return
+	}
+	hg.vault = unlocked
+	hg.appPrefs.LastDescription = contents.LastDescription
+	hg.descriptionEntry.SetText(contents.LastDescription)
+	hg.filterEntry.SetText(contents.LastFilter)
+
+	hg.migratePlaintextSettings()
+	hg.settingsLoaded()
+}
+
+// Move any plaintext settings into the vault. Settings already in the vault win,
+// in case an earlier migration was interrupted after the vault was written.
+func (hg *HashGenerator) migratePlaintextSettings() {
+	settingsData := hg.store.Get(settingsDocument)
+	if settingsData == "" || hg.readOnly != nil {
+		return
+	}
+	plaintext, err := schema.Unmarshal([]byte(settingsData))
+	if err != nil {
+		// Leave anything unreadable where it is rather than lose it
+		dialog.ShowError(fmt.Errorf("error parsing unencrypted settings: %v", err), hg.window)
+		return
+	}
+	for key, setting := range plaintext {
+		if _, exists := hg.savedSettings[key]; !exists {
+			hg.savedSettings[key] = setting
+		}
+	}
+	hg.saveVault()
+	if err = hg.store.Remove(settingsDocument); err != nil {
+		dialog.ShowError(fmt.Errorf("error removing unencrypted settings: %v", err), hg.window)
+	}
+}
+
+// Start encrypting the settings, change the vault passphrase, or go back to storing them unencrypted
+func (hg *HashGenerator) editVault() {
+	if !hg.encrypted() {
+		hg.setVaultPassphrase("Encrypt Settings", "Encrypt")
+		return
+	}
+
+	var d dialog.Dialog
+	changeButton := widget.NewButton("Change Passphrase", func() {
+		d.Hide()
+		hg.setVaultPassphrase("Change Passphrase", "Change")
+	})
+	decryptButton := widget.NewButton("Stop Encrypting", func() {
+		d.Hide()
+		dialog.ShowConfirm("Stop Encrypting",
+			"Store the saved settings unencrypted? Anyone with access to this device's files will be able to read them.",
+			func(confirmed bool) {
+				if confirmed {
+					hg.removeVault()
+				}
+			}, hg.window)
+	})
+	d = dialog.NewCustom("Encrypted Settings", "Close", container.NewVBox(changeButton, decryptButton), hg.window)
+	d.Show()
+}
+
+// Encrypt with a new passphrase, which makes a new vault (with a new salt) either way
+func (hg *HashGenerator) setVaultPassphrase(title, confirm string) {
+	passphraseEntry := widget.NewPasswordEntry()
+	passphraseEntry.Validator = func(text string) error {
+		if text == "" {
+			return fmt.Errorf("passphrase cannot be empty")
+		}
+		return nil
+	}
+	confirmEntry := widget.NewPasswordEntry()
+	confirmEntry.Validator = func(text string) error {
+		if text != passphraseEntry.Text {
+			return fmt.Errorf("passphrases don't match")
+		}
+		return nil
+	}
+	explanation := widget.NewLabel("The passphrase will be needed every time HM3k starts. It can't be recovered if it's forgotten.")
+	explanation.Wrapping = fyne.TextWrapWord
+
+	d := dialog.NewForm(title, confirm, "Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("", explanation),
+			widget.NewFormItem("Passphrase", passphraseEntry),
+			widget.NewFormItem("Confirm", confirmEntry),
+		},
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			passphrase := passphraseEntry.Text
+			go func() {
+				created, err := vault.Create(passphrase)
+				fyne.Do(func() {
+					if err != nil {
+						dialog.ShowError(fmt.Errorf("error creating vault: %v", err), hg.window)
+						return
+					}
+					// Write the vault before removing anything unencrypted
+					hg.vault = created
+					hg.saveVault()
+					hg.migratePlaintextSettings()
+					hg.saveAppPreferences()
+					hg.updateVaultButton()
+				})
+			}()
+		}, hg.window)
+	hg.showWide(d)
+}
+
+// Go back to storing the settings unencrypted
+func (hg *HashGenerator) removeVault() {
+	// Write the plaintext before removing the vault
+	data, err := schema.Marshal(hg.savedSettings)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("error encoding settings: %v", err), hg.window)
+		return
+	}
+	if err = hg.store.Set(settingsDocument, string(data)); err != nil {
+		dialog.ShowError(fmt.Errorf("error saving settings: %v", err), hg.window)
+		return
+	}
+	if err = hg.store.Remove(vaultDocument); err != nil {
+		dialog.ShowError(fmt.Errorf("error removing encrypted settings: %v", err), hg.window)
+		return
+	}
+	hg.vault = nil
+	hg.saveAppPreferences()
+	hg.updateVaultButton()
+}
@@ -5,7 +5,6 @@
 package main
 
 import (
-	"strconv"
 	"strings"
 )
 
@@ -25,12 +24,16 @@ func (hg *HashGenerator) updateFilteredKeys(filterText string) {
 
 		// Check if we should hide hobbled settings
 		if hg.hideZeroIterBox.Checked {
-			iterations, err := strconv.Atoi(setting.Iterations)
-			if err == nil && iterations <= 0 {
+			if setting.Iterations <= 0 {
 				continue // Skip this setting if it has 0 iterations and we're hiding them
 			}
 		}
 
+		// Only show the chosen identity's settings
+		if filter := hg.appPrefs.FilterIdentity; filter != "" && filter != allIdentitiesLabel && filter != identityLabel(setting.Identity) {
+			continue
+		}
+
 		// Apply text filter
 		if filterText == "" || strings.Contains(strings.ToLower(key), filterLower) {
 			hg.filteredKeys = append(hg.filteredKeys, key)
@@ -0,0 +1,40 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"time"
+
+	"HashMaster3000/derive"
+
+	"fyne.io/fyne/v2"
+)
+
+// Wait for a pause in typing before hashing the master for its fingerprint
+const fingerprintDelay = 400 * time.Millisecond
+
+// Show the fingerprint of the master password once it stops changing. Nothing is stored.
+func (hg *HashGenerator) updateFingerprint(master string) {
+	// Results for anything but the latest text are discarded
+	hg.fingerprintSeq++
+	seq := hg.fingerprintSeq
+	if hg.fingerprintTimer != nil {
+		hg.fingerprintTimer.Stop()
+	}
+	if master == "" {
+		hg.fingerprintLabel.SetText("")
+		return
+	}
+	hg.fingerprintLabel.SetText("...")
+
+	hg.fingerprintTimer = time.AfterFunc(fingerprintDelay, func() {
+		fingerprint := derive.Fingerprint(master)
+		fyne.Do(func() {
+			if seq == hg.fingerprintSeq {
+				hg.fingerprintLabel.SetText(fingerprint)
+			}
+		})
+	})
+}
@@ -5,6 +5,14 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"time"
+
+	"HashMaster3000/derive"
+	"HashMaster3000/store"
+	"HashMaster3000/vault"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/widget"
@@ -13,24 +21,55 @@ import (
 type HashGenerator struct {
 	descriptionEntry *widget.Entry
 	masterPassEntry  *widget.Entry
+	fingerprintLabel *widget.Label
+	fingerprintTimer *time.Timer
+	fingerprintSeq   int // so only the fingerprint of the latest master is shown
+	verifierButton   *widget.Button
+	identitySelect   *widget.Select
+	identityButton   *widget.Button
+	identity         string // the identity whose master is on the form, empty for the default
 	algorithmSelect  *widget.Select
+	schemeSelect     *widget.Select
+	encodingSelect   *widget.Select
 	charRestSelect   *widget.Select
+	charRestButton   *widget.Button
+	lengthLabel      *widget.Label
 	lengthEntry      *widget.Entry
+	policyButton     *widget.Button
 	iterationsEntry  *widget.Entry
+	benchmarkButton  *widget.Button
+	memoryEntry      *widget.Entry
+	parallelismEntry *widget.Entry
 	genButton        *widget.Button
 	outputEntry      *widget.Entry
+	progressBar      *widget.ProgressBar
+	progressBusy     *widget.ProgressBarInfinite // for the schemes that can't report progress
+	cancelButton     *widget.Button
+	progressRow      *fyne.Container
+	cancelDerive     context.CancelFunc // set while a derivation is running
 	app              fyne.App
 	window           fyne.Window
 	savedSettings    map[string]SavedSetting
 	settingsList     *widget.List
 	filterEntry      *widget.Entry
+	identityFilter   *widget.Select
 	filteredKeys     []string
 	backupButton     *widget.Button
 	mergeButton      *widget.Button
 	restoreButton    *widget.Button
+	vaultButton      *widget.Button
+	storeButton      *widget.Button
 	hideZeroIterBox  *widget.Check
 	copyToClipboard  *widget.Check
 	appPrefs         AppPreferences
+	readOnly         error // why nothing is being saved, if a stored document couldn't be read
+	store            store.SettingsStore
+	storeConfig      store.Config
+	portable         bool                          // the settings are kept next to the executable
+	vault            *vault.Vault                  // nil unless the settings are encrypted and unlocked
+	policy           derive.Policy                 // edited in a dialog rather than on the form
+	customCharsets   map[derive.CustomCharset]bool // every definition seen, since names needn't be unique
+	passphrase       derive.PassphraseOptions      // edited in a dialog rather than on the form
 }
 
 func main() {
@@ -43,19 +82,30 @@ func main() {
 	})
 
 	generator := &HashGenerator{
-		app:           myApp,
-		window:        myWindow,
-		savedSettings: make(map[string]SavedSetting),
-		filteredKeys:  []string{},
-		appPrefs:      AppPreferences{}, // Initialize preferences
+		app:            myApp,
+		window:         myWindow,
+		savedSettings:  make(map[string]SavedSetting),
+		filteredKeys:   []string{},
+		customCharsets: make(map[derive.CustomCharset]bool),
+		appPrefs:       AppPreferences{}, // Initialize preferences
 	}
-	generator.loadAppPreferences()
+	flag.Parse()
+	config, err := generator.readStoreConfig()
+	generator.storeConfig = config
 
 	appLife := myApp.Lifecycle()
-	appLife.SetOnStarted(generator.loadSettings)
-
-	generator.makeUIcomponents()
-	myWindow.SetContent(generator.layoutUI())
-	myWindow.Canvas().Focus(generator.filterEntry)
+	appLife.SetOnExitedForeground(generator.flushStore)
+	appLife.SetOnStopped(generator.flushStore)
+	if err != nil {
+		appLife.SetOnStarted(func() { generator.quitWithError(err) })
+	} else if generator.storeConfig.Encrypted() {
+		// The passphrase is asked for once the window is up
+		appLife.SetOnStarted(generator.unlockStore)
+	} else if err = generator.openStore(""); err != nil {
+		appLife.SetOnStarted(func() { generator.quitWithError(err) })
+	} else {
+		generator.start()
+		appLife.SetOnStarted(generator.loadSettings)
+	}
 	myWindow.ShowAndRun()
 }
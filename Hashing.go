@@ -5,20 +5,25 @@
 package main
 
 import (
-	"crypto/md5"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/sha512"
-	"encoding/base64"
+	"context"
+	"errors"
 	"fmt"
-	"hash"
-	"regexp"
-	"strconv"
+	"time"
 
+	"HashMaster3000/derive"
+
+	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/dialog"
 )
 
+// Derivations that finish quicker than this don't flash up the progress bar
+const progressDelay = 250 * time.Millisecond
+
 func (hg *HashGenerator) generateHash() {
+	// Ignore repeat requests (e.g. pressing Return) while a slow derivation is still running
+	if hg.genButton.Disabled() {
+		return
+	}
 
 	if hg.descriptionEntry.Validate() != nil {
 		return
@@ -29,99 +34,113 @@ func (hg *HashGenerator) generateHash() {
 	if hg.masterPassEntry.Validate() != nil || hg.iterationsEntry.Validate() != nil || hg.lengthEntry.Validate() != nil {
 		return
 	}
-	masterPass := hg.masterPassEntry.Text
-
-	// Combine the tokens
-	combined := description + masterPass
-
-	// Get the hash using system commands with iterations
-	hash, err := hg.getHashWithIterations(combined, hg.algorithmSelect.Selected, hg.iterationsEntry.Text)
-	if err != nil {
-		dialog.ShowError(fmt.Errorf("hashing failed: %v", err), hg.window)
+	if derive.UsesMemoryParams(idFor(schemeLabels, hg.schemeSelect.Selected)) &&
+		(hg.memoryEntry.Validate() != nil || hg.parallelismEntry.Validate() != nil) {
 		return
 	}
 
-	// Apply character restrictions
-	processed := hg.applyCharacterRestrictions(hash, hg.charRestSelect.Selected)
-
-	// Apply length restriction
-	length, err := strconv.Atoi(hg.lengthEntry.Text)
-	if err == nil && length > 0 && len(processed) > length {
-		processed = processed[:length]
-	}
-
-	// Set the output
-	hg.outputEntry.SetText(processed)
+	setting := hg.currentSetting()
+	masterPass := hg.masterPassEntry.Text
 
-	// Copy to clipboard
-	if hg.copyToClipboard.Checked {
-		hg.app.Clipboard().SetContent(processed)
-	}
+	// Large iteration counts and the memory-hard schemes can take seconds,
+	// so derive in the background to keep the window responsive
+	ctx, cancel := context.WithCancel(context.Background())
+	hg.startProgress(cancel)
+	hg.outputEntry.SetText("")
+	verifiers := hg.verifiers()
+	go func() {
+		// Warn before generating from a master that doesn't match any of the stored verifiers
+		if !masterVerified(verifiers, masterPass) {
+			fyne.Do(func() {
+				dialog.ShowConfirm("Unrecognised Master Pass",
+					"The master pass doesn't match any of the stored verifiers, it may be mistyped. Generate anyway?",
+					func(proceed bool) {
+						if !proceed {
+							cancel()
+							hg.stopProgress()
+							return
+						}
+						go hg.deriveInBackground(ctx, cancel, setting, masterPass)
+					}, hg.window)
+			})
+			return
+		}
+		hg.deriveInBackground(ctx, cancel, setting, masterPass)
+	}()
 }
 
-func (hg *HashGenerator) getHashWithIterations(input string, algorithm string, iterations string) (string, error) {
-	iterCount, err := strconv.Atoi(iterations)
-	if err != nil || iterCount < 1 {
-		return "", fmt.Errorf("invalid iteration count: %s", iterations)
+// Derives off the UI thread, then posts the result back to the output and clipboard
+func (hg *HashGenerator) deriveInBackground(ctx context.Context, cancel context.CancelFunc, setting SavedSetting, masterPass string) {
+	// Only post progress to the UI when the bar would visibly move
+	lastPercent := -1
+	progress := func(done, total int) {
+		percent := done * 100 / total
+		if percent == lastPercent {
+			return
+		}
+		lastPercent = percent
+		fyne.Do(func() {
+			hg.setProgress(float64(done) / float64(total))
+		})
 	}
 
-	result := []byte(input)
-	for i := 0; i < iterCount; i++ {
-		result, err = hg.getHash(result, algorithm)
+	// The derivation itself lives in the UI independent derive package
+	processed, err := derive.DeriveContext(ctx, setting, masterPass, progress)
+	cancel()
+
+	fyne.Do(func() {
+		hg.stopProgress()
+		if errors.Is(err, context.Canceled) {
+			return
+		}
 		if err != nil {
-			return "", fmt.Errorf("iteration %d failed: %v", i+1, err)
+			dialog.ShowError(fmt.Errorf("hashing failed: %v", err), hg.window)
+			return
 		}
-	}
 
-	return base64.StdEncoding.EncodeToString(result), nil
+		// Set the output
+		hg.outputEntry.SetText(processed)
+
+		// Copy to clipboard
+		if hg.copyToClipboard.Checked {
+			hg.app.Clipboard().SetContent(processed)
+		}
+	})
 }
 
-func (hg *HashGenerator) getHash(input []byte, algorithm string) ([]byte, error) {
-	var h hash.Hash
-
-	switch algorithm {
-	case "SHA-256":
-		h = sha256.New()
-	case "SHA-512":
-		h = sha512.New()
-	case "SHA-1":
-		h = sha1.New()
-	case "MD5":
-		h = md5.New()
-	case "SHA-224":
-		h = sha256.New224()
-	case "SHA-384":
-		h = sha512.New384()
-	default:
-		return nil, fmt.Errorf("unsupported algorithm: %s", algorithm)
-	}
+// Disables generation while a derivation runs, and shows the progress row if it's still going after a moment.
+// The bar is indeterminate until the derivation reports some progress.
+func (hg *HashGenerator) startProgress(cancel context.CancelFunc) {
+	hg.genButton.Disable()
+	hg.cancelDerive = cancel
+	hg.progressBar.SetValue(0)
+	hg.progressBar.Hide()
+	hg.progressBusy.Show()
+
+	time.AfterFunc(progressDelay, func() {
+		fyne.Do(func() {
+			if hg.genButton.Disabled() {
+				if hg.progressBusy.Visible() {
+					hg.progressBusy.Start()
+				}
+				hg.progressRow.Show()
+			}
+		})
+	})
+}
 
-	_, err := h.Write(input)
-	if err != nil {
-		return nil, err
+func (hg *HashGenerator) setProgress(value float64) {
+	if hg.progressBusy.Visible() {
+		hg.progressBusy.Stop()
+		hg.progressBusy.Hide()
+		hg.progressBar.Show()
 	}
-
-	return h.Sum(nil), nil
+	hg.progressBar.SetValue(value)
 }
 
-func (hg *HashGenerator) applyCharacterRestrictions(hash, restriction string) string {
-	switch restriction {
-	case "All generated chars":
-		return hash
-	case "Alphanumeric (replace others with underscore)":
-		re := regexp.MustCompile(`[^a-zA-Z0-9]`)
-		return re.ReplaceAllString(hash, "_")
-	case "Alphanumeric (omit others)":
-		re := regexp.MustCompile(`[^a-zA-Z0-9]`)
-		return re.ReplaceAllString(hash, "")
-	case "Alpha only":
-		re := regexp.MustCompile(`[^a-zA-Z]`)
-		return re.ReplaceAllString(hash, "")
-	case "Numeric only":
-		re := regexp.MustCompile(`[^0-9]`)
-		result := re.ReplaceAllString(hash, "")
-		return result
-	default:
-		return hash
-	}
+func (hg *HashGenerator) stopProgress() {
+	hg.progressBusy.Stop()
+	hg.progressRow.Hide()
+	hg.cancelDerive = nil
+	hg.genButton.Enable()
 }
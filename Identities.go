@@ -0,0 +1,172 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"HashMaster3000/derive"
+
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Settings that don't name an identity belong to the default one
+const defaultIdentityLabel = "Default"
+
+// The identity filter option that shows every setting
+const allIdentitiesLabel = "All identities"
+
+// A named master password, for people who keep more than one (e.g. personal and work)
+type Identity struct {
+	Name      string            `json:"name"`
+	Verifiers []derive.Verifier `json:"verifiers,omitempty"`
+}
+
+func identityLabel(name string) string {
+	if name == "" {
+		return defaultIdentityLabel
+	}
+	return name
+}
+
+func identityFromLabel(label string) string {
+	if label == defaultIdentityLabel {
+		return ""
+	}
+	return label
+}
+
+// The default identity, then the named ones
+func (hg *HashGenerator) identityLabels() []string {
+	labels := []string{defaultIdentityLabel}
+	for _, identity := range hg.appPrefs.Identities {
+		labels = append(labels, identity.Name)
+	}
+	return labels
+}
+
+func (hg *HashGenerator) updateIdentityOptions() {
+	hg.identitySelect.SetOptions(hg.identityLabels())
+	hg.identityFilter.SetOptions(append([]string{allIdentitiesLabel}, hg.identityLabels()...))
+}
+
+// Add a named identity if it's not already known
+func (hg *HashGenerator) addIdentity(name string) {
+	if name == "" || slices.ContainsFunc(hg.appPrefs.Identities, func(identity Identity) bool { return identity.Name == name }) {
+		return
+	}
+	hg.appPrefs.Identities = append(hg.appPrefs.Identities, Identity{Name: name})
+	hg.saveAppPreferences()
+	hg.updateIdentityOptions()
+}
+
+// Settings from a backup or another device may name identities this one hasn't got yet
+func (hg *HashGenerator) collectIdentities() {
+	for _, key := range hg.getSettingsKeys() {
+		hg.addIdentity(hg.savedSettings[key].Identity)
+	}
+}
+
+// Switch to an identity. The master pass on the form belongs to the old one, so it's cleared.
+func (hg *HashGenerator) setIdentity(name string) {
+	if name == hg.identity {
+		return
+	}
+	hg.addIdentity(name)
+	hg.identity = name
+	hg.appPrefs.LastIdentity = name
+	hg.saveAppPreferences()
+
+	hg.identitySelect.SetSelected(identityLabel(name))
+	hg.masterPassEntry.SetText("")
+	hg.masterPassEntry.SetPlaceHolder(fmt.Sprintf("Enter master pass for %s...", identityLabel(name)))
+	hg.setVerifiers(hg.verifiers())
+}
+
+// The verifiers of the current identity. The default identity's are kept where they were before identities existed.
+func (hg *HashGenerator) verifiers() []derive.Verifier {
+	if hg.identity == "" {
+		return hg.appPrefs.Verifiers
+	}
+	for _, identity := range hg.appPrefs.Identities {
+		if identity.Name == hg.identity {
+			return identity.Verifiers
+		}
+	}
+	return nil
+}
+
+// Ask for the master pass of the identity a setting was just loaded for
+func (hg *HashGenerator) promptForMaster() {
+	passEntry := widget.NewPasswordEntry()
+	dialog.ShowForm(fmt.Sprintf("Master Pass for %s", identityLabel(hg.identity)), "OK", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Master Pass", passEntry)},
+		func(confirmed bool) {
+			if confirmed {
+				hg.masterPassEntry.SetText(passEntry.Text)
+			}
+		}, hg.window)
+	hg.window.Canvas().Focus(passEntry)
+}
+
+// Add and remove named identities. The default identity is always there.
+func (hg *HashGenerator) editIdentities() {
+	list := container.NewVBox()
+	var refresh func()
+	refresh = func() {
+		list.RemoveAll()
+		list.Add(widget.NewLabel(defaultIdentityLabel))
+		for i, identity := range hg.appPrefs.Identities {
+			removeButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+				// Settings can't be left pointing at an identity that's gone
+				inUse := 0
+				for _, setting := range hg.savedSettings {
+					if setting.Identity == identity.Name {
+						inUse++
+					}
+				}
+				if inUse > 0 {
+					dialog.ShowInformation("Identity In Use",
+						fmt.Sprintf("%d saved settings use '%s'.", inUse, identity.Name), hg.window)
+					return
+				}
+				if hg.identity == identity.Name {
+					hg.setIdentity("")
+				}
+				hg.appPrefs.Identities = slices.Delete(slices.Clone(hg.appPrefs.Identities), i, i+1)
+				hg.saveAppPreferences()
+				hg.updateIdentityOptions()
+				refresh()
+			})
+			list.Add(container.NewBorder(nil, nil, nil, removeButton, widget.NewLabel(identity.Name)))
+		}
+	}
+	refresh()
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Name")
+	addButton := widget.NewButton("Add", func() {
+		name := nameEntry.Text
+		if name == "" || slices.Contains(hg.identityLabels(), name) || name == allIdentitiesLabel {
+			dialog.ShowInformation("Identity", "Enter a new name for the identity.", hg.window)
+			return
+		}
+		hg.addIdentity(name)
+		nameEntry.SetText("")
+		refresh()
+	})
+
+	content := container.NewVBox(
+		list,
+		widget.NewSeparator(),
+		container.NewBorder(nil, nil, nil, addButton, nameEntry),
+	)
+	d := dialog.NewCustom("Identities", "Close", content, hg.window)
+	hg.showWide(d)
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"HashMaster3000/derive"
+
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Set the passphrase formatting options
+func (hg *HashGenerator) setPassphrase(options derive.PassphraseOptions) {
+	hg.passphrase = options
+	hg.appPrefs.LastPassphrase = options
+	hg.saveAppPreferences()
+}
+
+// Edit the separator, capitalisation and digit options of the passphrase restriction
+func (hg *HashGenerator) editPassphrase() {
+	separatorEntry := widget.NewEntry()
+	separatorEntry.SetPlaceHolder("None")
+	separatorEntry.SetText(hg.passphrase.Separator)
+	capitaliseCheck := widget.NewCheck("", nil)
+	capitaliseCheck.SetChecked(hg.passphrase.Capitalise)
+	digitCheck := widget.NewCheck("", nil)
+	digitCheck.SetChecked(hg.passphrase.Digit)
+
+	dialog.ShowForm("Passphrase Options", "OK", "Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("Separator", separatorEntry),
+			widget.NewFormItem("Capitalise", capitaliseCheck),
+			widget.NewFormItem("Add a digit", digitCheck),
+		},
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			hg.setPassphrase(derive.PassphraseOptions{
+				Separator:  separatorEntry.Text,
+				Capitalise: capitaliseCheck.Checked,
+				Digit:      digitCheck.Checked,
+			})
+		}, hg.window)
+}
+
+// The options button edits whichever restriction is selected, if it has any options.
+// Passphrases also count their length in words.
+func (hg *HashGenerator) updateCharRestOptions() {
+	restriction, _ := hg.charRestFromLabel(hg.charRestSelect.Selected)
+	switch restriction {
+	case derive.RestrictPassphrase, derive.RestrictCustom:
+		hg.charRestButton.Enable()
+	default:
+		hg.charRestButton.Disable()
+	}
+	if restriction == derive.RestrictPassphrase {
+		hg.lengthLabel.SetText("Words:")
+	} else {
+		hg.lengthLabel.SetText("Length:")
+	}
+}
+
+func (hg *HashGenerator) editCharRestOptions() {
+	restriction, _ := hg.charRestFromLabel(hg.charRestSelect.Selected)
+	switch restriction {
+	case derive.RestrictPassphrase:
+		hg.editPassphrase()
+	case derive.RestrictCustom:
+		hg.defineCustomCharset()
+	}
+}
@@ -8,43 +8,55 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strconv"
+	"strings"
+
+	"HashMaster3000/derive"
+	"HashMaster3000/schema"
 
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
 )
 
-type SavedSetting struct {
-	Description      string `json:"description"`
-	Algorithm        string `json:"algorithm"`
-	CharRestrictions string `json:"char_restrictions"`
-	Length           string `json:"length"`
-	Iterations       string `json:"iterations"`
-}
+// The setting type is defined alongside the derivation it parameterises
+type SavedSetting = derive.SavedSetting
 
 type AppPreferences struct {
-	LastDescription string `json:"last_description"`
-	LastFilter      string `json:"last_filter"`
-	LastAlgorithm   string `json:"last_algorithm"`
-	LastCharRest    string `json:"last_char_rest"`
-	LastLength      string `json:"last_length"`
-	LastIter        string `json:"last_iterations"`
-	HideZeroIter    bool   `json:"hide_zero_iter"`
-	CopyToClipboard bool   `json:"copy_to_clipboard"`
+	LastDescription string                            `json:"last_description"`
+	LastFilter      string                            `json:"last_filter"`
+	LastAlgorithm   string                            `json:"last_algorithm"`
+	LastScheme      string                            `json:"last_scheme"`
+	LastEncoding    string                            `json:"last_encoding"`
+	LastCharRest    string                            `json:"last_char_rest"`
+	LastLength      string                            `json:"last_length"`
+	LastIter        string                            `json:"last_iterations"`
+	LastMemory      string                            `json:"last_memory"`
+	LastParallelism string                            `json:"last_parallelism"`
+	LastPolicy      derive.Policy                     `json:"last_policy,omitzero"`
+	LastCustom      derive.CustomCharset              `json:"last_custom,omitzero"`
+	LastPassphrase  derive.PassphraseOptions          `json:"last_passphrase"`
+	Verifiers       []derive.Verifier                 `json:"verifiers,omitempty"` // for the default identity
+	Identities      []Identity                        `json:"identities,omitempty"`
+	LastIdentity    string                            `json:"last_identity,omitempty"`
+	FilterIdentity  string                            `json:"filter_identity,omitempty"` // an identity label, empty for all
+	HideZeroIter    bool                              `json:"hide_zero_iter"`
+	CopyToClipboard bool                              `json:"copy_to_clipboard"`
+	Rates           map[string]map[string]derive.Rate `json:"rates,omitempty"` // measured by the benchmark, by device then rateKey
 }
 
-// Settings persistence functions using Fyne preferences
+// Settings persistence functions, using the settings store
 func (hg *HashGenerator) saveSetting(description string) {
 	if description == "" {
 		return
 	}
-
-	setting := SavedSetting{
-		Description:      description,
-		Algorithm:        hg.algorithmSelect.Selected,
-		CharRestrictions: hg.charRestSelect.Selected,
-		Length:           hg.lengthEntry.Text,
-		Iterations:       hg.iterationsEntry.Text,
+	// Only numbers can be saved, though 0 iterations can be, to mark the setting inactive
+	if !hg.formNumbersValid() {
+		return
 	}
 
+	setting := hg.currentSetting()
+	setting.Description = description
+
 	hg.savedSettings[description] = setting
 	hg.saveSettingsToPreferences()
 
@@ -53,17 +65,112 @@ func (hg *HashGenerator) saveSetting(description string) {
 	hg.settingsList.Refresh()
 }
 
+// Build a setting from the current state of the form
+func (hg *HashGenerator) currentSetting() SavedSetting {
+	restriction, custom := hg.charRestFromLabel(hg.charRestSelect.Selected)
+	setting := SavedSetting{
+		Description:      hg.descriptionEntry.Text,
+		Algorithm:        idFor(algorithmLabels, hg.algorithmSelect.Selected),
+		CharRestrictions: restriction,
+		Custom:           custom,
+		Length:           entryInt(hg.lengthEntry),
+		Iterations:       entryInt(hg.iterationsEntry),
+		Scheme:           omitDefault(idFor(schemeLabels, hg.schemeSelect.Selected), derive.SchemeConcatenate),
+		Encoding:         omitDefault(idFor(encodingLabels, hg.encodingSelect.Selected), derive.EncodingBase64),
+		Policy:           hg.policy,
+		Identity:         hg.identity,
+	}
+	if setting.CharRestrictions == derive.RestrictPassphrase {
+		setting.Passphrase = hg.passphrase
+	}
+	// The rotation counter and history aren't on the form, they belong to the saved description
+	if saved, exists := hg.savedSettings[setting.Description]; exists {
+		setting.Counter = saved.Counter
+		setting.History = saved.History
+	}
+	// Only keep the memory-hard KDF parameters for the schemes that use them
+	if derive.UsesMemoryParams(setting.Scheme) {
+		setting.Memory = entryInt(hg.memoryEntry)
+		setting.Parallelism = entryInt(hg.parallelismEntry)
+	}
+	return setting
+}
+
+// The number in an entry, or 0 if it's empty (or isn't a number)
+func entryInt(entry *widget.Entry) int {
+	number, _ := strconv.Atoi(entry.Text)
+	return number
+}
+
+// Reports whether the numeric entries hold numbers (or nothing, for the length)
+func (hg *HashGenerator) formNumbersValid() bool {
+	isInt := func(text string) bool {
+		_, err := strconv.Atoi(text)
+		return err == nil
+	}
+	if hg.lengthEntry.Text != "" && !isInt(hg.lengthEntry.Text) {
+		return false
+	}
+	if !isInt(hg.iterationsEntry.Text) {
+		return false
+	}
+	if derive.UsesMemoryParams(idFor(schemeLabels, hg.schemeSelect.Selected)) {
+		return isInt(hg.memoryEntry.Text) && isInt(hg.parallelismEntry.Text)
+	}
+	return true
+}
+
+// Fields added after the first release store their default as empty,
+// so settings saved before the field existed still compare equal
+func omitDefault(selected, defaultValue string) string {
+	if selected == defaultValue {
+		return ""
+	}
+	return selected
+}
+
+func withDefault(stored, defaultValue string) string {
+	if stored == "" {
+		return defaultValue
+	}
+	return stored
+}
+
 func (hg *HashGenerator) loadSetting(key string) {
 	setting, exists := hg.savedSettings[key]
 	if !exists {
 		return
 	}
 
+	// Each setting is generated with its own identity's master
+	if setting.Identity != hg.identity {
+		hg.setIdentity(setting.Identity)
+		hg.promptForMaster()
+	}
+
 	hg.descriptionEntry.SetText(setting.Description)
-	hg.algorithmSelect.SetSelected(setting.Algorithm)
-	hg.charRestSelect.SetSelected(setting.CharRestrictions)
-	hg.lengthEntry.SetText(setting.Length)
-	hg.iterationsEntry.SetText(setting.Iterations)
+	hg.algorithmSelect.SetSelected(labelFor(algorithmLabels, setting.Algorithm))
+	hg.schemeSelect.SetSelected(labelFor(schemeLabels, withDefault(setting.Scheme, derive.SchemeConcatenate)))
+	hg.encodingSelect.SetSelected(labelFor(encodingLabels, withDefault(setting.Encoding, derive.EncodingBase64)))
+	if setting.CharRestrictions == derive.RestrictCustom {
+		hg.addCustomCharset(setting.Custom)
+	}
+	if setting.CharRestrictions == derive.RestrictPassphrase {
+		hg.setPassphrase(setting.Passphrase)
+	}
+	hg.charRestSelect.SetSelected(hg.charRestLabel(setting))
+	// A length of 0 is no restriction, which is an empty entry
+	if setting.Length == 0 {
+		hg.lengthEntry.SetText("")
+	} else {
+		hg.lengthEntry.SetText(strconv.Itoa(setting.Length))
+	}
+	hg.iterationsEntry.SetText(strconv.Itoa(setting.Iterations))
+	hg.setPolicy(setting.Policy)
+	if derive.UsesMemoryParams(setting.Scheme) {
+		hg.memoryEntry.SetText(strconv.Itoa(setting.Memory))
+		hg.parallelismEntry.SetText(strconv.Itoa(setting.Parallelism))
+	}
 }
 
 func (hg *HashGenerator) deleteSetting(key string) {
@@ -88,24 +195,47 @@ func (hg *HashGenerator) getSettingsKeys() []string {
 	return keys
 }
 
-// Save settings to Fyne preferences
+// Save settings to the settings store
 func (hg *HashGenerator) saveSettingsToPreferences() {
-	data, err := json.Marshal(hg.savedSettings)
+	if hg.readOnly != nil {
+		return
+	}
+	if hg.encrypted() {
+		hg.saveVault()
+		return
+	}
+
+	data, err := schema.Marshal(hg.savedSettings)
 	if err != nil {
 		dialog.ShowError(fmt.Errorf("error encoding settings: %v", err), hg.window)
 		return
 	}
-	hg.app.Preferences().SetString("savedSettings", string(data))
+	if err = hg.store.Set(settingsDocument, string(data)); err != nil {
+		dialog.ShowError(fmt.Errorf("error saving settings: %v", err), hg.window)
+	}
 }
 
 // Save app preferences (filter, last used settings, etc.)
 func (hg *HashGenerator) saveAppPreferences() {
-	data, err := json.Marshal(hg.appPrefs)
+	if hg.readOnly != nil {
+		return
+	}
+	prefs := hg.appPrefs
+	// With encrypted settings, the preferences that would give away accounts are kept in the vault instead
+	if hg.encrypted() {
+		prefs.LastDescription = ""
+		prefs.LastFilter = ""
+		hg.saveVault()
+	}
+
+	data, err := json.Marshal(prefs)
 	if err != nil {
 		dialog.ShowError(fmt.Errorf("error encoding app preferences: %v", err), hg.window)
 		return
 	}
-	hg.app.Preferences().SetString("appPreferences", string(data))
+	if err = hg.store.Set(appPreferencesDocument, string(data)); err != nil {
+		dialog.ShowError(fmt.Errorf("error saving app preferences: %v", err), hg.window)
+	}
 }
 
 // Load app preferences and restore last used settings
@@ -114,7 +244,7 @@ func (hg *HashGenerator) loadAppPreferences() {
 	//load defaults first
 	hg.appPrefs = hg.DefaultAppPrefs()
 
-	prefsData := hg.app.Preferences().StringWithFallback("appPreferences", "")
+	prefsData := hg.store.Get(appPreferencesDocument)
 	if prefsData == "" {
 		// No saved preferences, stay with defaults
 		return
@@ -122,42 +252,87 @@ func (hg *HashGenerator) loadAppPreferences() {
 
 	err := json.Unmarshal([]byte(prefsData), &hg.appPrefs)
 	if err != nil {
-		// Error parsing preferences, reload defaults
+		// Error parsing preferences, reload defaults, but don't save them over the ones that couldn't be read
 		hg.appPrefs = hg.DefaultAppPrefs()
+		hg.markReadOnly(fmt.Errorf("error parsing app preferences: %v", err))
 		return
 	}
+
+	// Older versions stored the last algorithm, scheme, encoding and restriction by their labels
+	hg.appPrefs.LastAlgorithm = schema.AlgorithmID(hg.appPrefs.LastAlgorithm)
+	hg.appPrefs.LastScheme = schema.SchemeID(hg.appPrefs.LastScheme)
+	hg.appPrefs.LastEncoding = schema.EncodingID(hg.appPrefs.LastEncoding)
+	if strings.HasPrefix(hg.appPrefs.LastCharRest, customCharsetPrefix) {
+		hg.appPrefs.LastCharRest = derive.RestrictCustom
+	}
+	hg.appPrefs.LastCharRest = schema.RestrictionID(hg.appPrefs.LastCharRest)
 }
 
 func (hg *HashGenerator) DefaultAppPrefs() AppPreferences {
 	return AppPreferences{
 		LastDescription: "",
-		LastAlgorithm:   "SHA-256",
-		LastCharRest:    "Alphanumeric (replace others with underscore)",
+		LastAlgorithm:   derive.AlgorithmSHA256,
+		LastScheme:      derive.SchemeConcatenate,
+		LastEncoding:    derive.EncodingBase64,
+		LastCharRest:    derive.RestrictAlnumUnderscore,
 		LastLength:      "12",
 		LastIter:        "1",
+		LastMemory:      "65536",
+		LastParallelism: "1",
+		LastPassphrase:  derive.PassphraseOptions{Separator: "-"},
 		HideZeroIter:    true,
 		CopyToClipboard: true,
 		LastFilter:      "",
 	}
 }
 
-// Load settings from Fyne preferences
+// Load settings from the settings store
 func (hg *HashGenerator) loadSettings() {
-
-	// Load saved settings
-	settingsData := hg.app.Preferences().StringWithFallback("savedSettings", "")
-	if settingsData == "" {
-		// No saved settings, start with empty map
-		hg.savedSettings = make(map[string]SavedSetting)
-		hg.filterSettings(hg.filterEntry.Text)
+	// Encrypted settings wait for the vault to be unlocked
+	if hg.encrypted() {
+		hg.unlockVault()
 		return
 	}
 
-	err := json.Unmarshal([]byte(settingsData), &hg.savedSettings)
-	if err != nil {
-		dialog.ShowError(fmt.Errorf("error parsing saved settings: %v", err), hg.window)
-		hg.savedSettings = make(map[string]SavedSetting)
+	// Load saved settings. With none saved, start with an empty map,
+	// but still go through settingsLoaded in case the app preferences couldn't be read.
+	hg.savedSettings = make(map[string]SavedSetting)
+	if settingsData := hg.store.Get(settingsDocument); settingsData != "" {
+		// Settings saved by older versions are upgraded as they're read
+		settings, err := schema.Unmarshal([]byte(settingsData))
+		if err != nil {
+			hg.markReadOnly(fmt.Errorf("error parsing saved settings: %v", err))
+		} else {
+			hg.savedSettings = settings
+		}
+	}
+
+	hg.settingsLoaded()
+}
+
+// Stop saving anything for the rest of the session, so a document that couldn't be read
+// (e.g. one written by a newer version of HM3k) isn't overwritten by what could be read of it
+func (hg *HashGenerator) markReadOnly(err error) {
+	if hg.readOnly == nil {
+		hg.readOnly = err
 	}
+}
 
+// Disable what would only change settings that can't be saved
+func (hg *HashGenerator) disableEditing() {
+	hg.mergeButton.Disable()
+	hg.restoreButton.Disable()
+	hg.vaultButton.Disable()
+	hg.storeButton.Disable()
+}
+
+// Fill in everything that depends on the saved settings, once they're loaded
+func (hg *HashGenerator) settingsLoaded() {
+	if hg.readOnly != nil {
+		hg.disableEditing()
+		dialog.ShowError(fmt.Errorf("%v\n\nNothing will be saved until HM3k is restarted, so the stored settings aren't overwritten", hg.readOnly), hg.window)
+	}
+	hg.collectCustomCharsets()
+	hg.collectIdentities()
 	hg.filterSettings(hg.filterEntry.Text)
 }
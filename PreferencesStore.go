@@ -0,0 +1,31 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package main
+
+import "fyne.io/fyne/v2"
+
+// Keeps each document as a string in Fyne's per-user preferences, as HM3k always has
+type preferencesStore struct {
+	prefs fyne.Preferences
+}
+
+func (s *preferencesStore) Get(name string) string {
+	return s.prefs.StringWithFallback(name, "")
+}
+
+func (s *preferencesStore) Set(name, value string) error {
+	s.prefs.SetString(name, value)
+	return nil
+}
+
+func (s *preferencesStore) Remove(name string) error {
+	s.prefs.RemoveValue(name)
+	return nil
+}
+
+// Fyne already saves its preferences in the background
+func (s *preferencesStore) Flush() error {
+	return nil
+}
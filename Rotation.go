@@ -0,0 +1,84 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"HashMaster3000/derive"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Rotate a setting's password by bumping its counter, keeping the old parameters in its history
+func (hg *HashGenerator) rotateSetting(key string) {
+	dialog.ShowConfirm("Rotate Password",
+		fmt.Sprintf("Generate a new password for '%s'? The current one can still be recalled from its history.", key),
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			setting := hg.savedSettings[key]
+			previous := setting
+			previous.History = nil
+			setting.History = append(setting.History, previous)
+			setting.Counter++
+
+			hg.savedSettings[key] = setting
+			hg.saveSettingsToPreferences()
+			hg.settingsList.Refresh()
+			hg.showPasswordChange(key)
+		}, hg.window)
+}
+
+// Show the previous and current passwords side by side, for a site's change-password form
+func (hg *HashGenerator) showPasswordChange(key string) {
+	setting, exists := hg.savedSettings[key]
+	if !exists || len(setting.History) == 0 {
+		return
+	}
+	if hg.masterPassEntry.Validate() != nil {
+		dialog.ShowInformation("Master Pass", "Enter the master pass first.", hg.window)
+		return
+	}
+	masterPass := hg.masterPassEntry.Text
+	previousSetting := setting.History[len(setting.History)-1]
+
+	go func() {
+		previous, err := derive.Derive(previousSetting, masterPass)
+		if err != nil {
+			fyne.Do(func() {
+				dialog.ShowError(fmt.Errorf("hashing previous password failed: %v", err), hg.window)
+			})
+			return
+		}
+		current, err := derive.Derive(setting, masterPass)
+		if err != nil {
+			fyne.Do(func() {
+				dialog.ShowError(fmt.Errorf("hashing failed: %v", err), hg.window)
+			})
+			return
+		}
+
+		fyne.Do(func() {
+			row := func(label, password string) fyne.CanvasObject {
+				entry := widget.NewPasswordEntry()
+				entry.SetText(password)
+				entry.TextStyle = fyne.TextStyle{Monospace: true}
+				copyButton := widget.NewButton("Copy", func() {
+					hg.app.Clipboard().SetContent(password)
+				})
+				return container.NewBorder(nil, nil, widget.NewLabel(label), copyButton, entry)
+			}
+			dialog.ShowCustom(key, "Close", container.NewVBox(
+				row("Old:", previous),
+				row("New:", current),
+			), hg.window)
+		})
+	}()
+}
@@ -0,0 +1,305 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"HashMaster3000/store"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// The documents kept in the settings store
+const (
+	settingsDocument       = "savedSettings"
+	appPreferencesDocument = "appPreferences"
+	vaultDocument          = "vault"
+)
+
+var storeDocuments = []string{settingsDocument, appPreferencesDocument, vaultDocument}
+
+// Which store to use is always kept in Fyne's preferences, since it's needed to find everything else
+const storeConfigKey = "settingsStore"
+
+// The command line overrides the configured store, e.g. to get back to settings that can't be opened
+var (
+	storeFlag     = flag.String("store", "", "where to keep the settings: "+strings.Join(store.Types, ", "))
+	storePathFlag = flag.String("store-path", "", "the file to keep the settings in, for the file stores")
+	portableFlag  = flag.Bool("portable", false, "keep the settings in a file next to the executable")
+)
+
+// In portable mode the settings go everywhere the executable does, e.g. on a USB stick.
+// It's turned on by the flag, or by a marker file next to the executable.
+const (
+	portableMarker = "HM3k.portable"
+	portableFile   = "HM3k-settings.json"
+)
+
+var storeLabels = map[string]string{
+	store.TypePreferences:   "This device",
+	store.TypeFile:          "File",
+	store.TypeEncryptedFile: "Encrypted file",
+}
+
+// The configured store, as overridden by portable mode and then the command line
+func (hg *HashGenerator) readStoreConfig() (store.Config, error) {
+	var config store.Config
+	if data := hg.app.Preferences().String(storeConfigKey); data != "" {
+		if err := json.Unmarshal([]byte(data), &config); err != nil {
+			config = store.Config{}
+		}
+	}
+	// Without the executable's location there's no marker to look for, which only matters if portable mode was asked for
+	dir, err := executableDir()
+	if err != nil && *portableFlag {
+		return config, fmt.Errorf("error finding the executable for portable mode: %v", err)
+	}
+	if err == nil {
+		if _, err = os.Stat(filepath.Join(dir, portableMarker)); err == nil || *portableFlag {
+			hg.portable = true
+			config = store.Config{Type: store.TypeFile, Path: filepath.Join(dir, portableFile)}
+		}
+	}
+	if *storeFlag != "" {
+		hg.portable = false
+		config = store.Config{Type: *storeFlag}
+	}
+	if *storePathFlag != "" {
+		config.Path = *storePathFlag
+	}
+	return config, nil
+}
+
+// The directory the executable is in, with any symlinks to it followed
+func executableDir() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(executable), nil
+}
+
+// Open the store a configuration describes. Only the encrypted file store uses the passphrase.
+func (hg *HashGenerator) newStore(config store.Config, passphrase string) (store.SettingsStore, error) {
+	if err := config.Check(); err != nil {
+		return nil, err
+	}
+	switch config.Type {
+	case store.TypeFile:
+		opened, err := store.OpenFile(config.Path)
+		if err != nil {
+			return nil, err
+		}
+		opened.SetOnError(hg.storeWriteFailed)
+		return opened, nil
+	case store.TypeEncryptedFile:
+		opened, err := store.OpenEncryptedFile(config.Path, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		opened.SetOnError(hg.storeWriteFailed)
+		return opened, nil
+	default:
+		return &preferencesStore{prefs: hg.app.Preferences()}, nil
+	}
+}
+
+// The file stores are written in the background, and only report the first of a run of failed writes
+func (hg *HashGenerator) storeWriteFailed(err error) {
+	fyne.Do(func() {
+		dialog.ShowError(fmt.Errorf("error saving settings: %v", err), hg.window)
+	})
+}
+
+// Write anything the store hasn't yet, before HM3k goes into the background or quits
+func (hg *HashGenerator) flushStore() {
+	if hg.store == nil {
+		return
+	}
+	if err := hg.store.Flush(); err != nil {
+		fyne.LogError("error saving settings", err)
+	}
+}
+
+// Open the configured store
+func (hg *HashGenerator) openStore(passphrase string) error {
+	opened, err := hg.newStore(hg.storeConfig, passphrase)
+	if err != nil {
+		return fmt.Errorf("error opening settings store: %v", err)
+	}
+	hg.store = opened
+	return hg.seedPortableStore()
+}
+
+// Start a new portable store with this device's settings, so the first run isn't empty
+func (hg *HashGenerator) seedPortableStore() error {
+	if !hg.portable || !isEmpty(hg.store) {
+		return nil
+	}
+	// Written straight away, so a drive that can't be written to is found now rather than part way through editing
+	err := copyDocuments(&preferencesStore{prefs: hg.app.Preferences()}, hg.store)
+	if err == nil {
+		err = hg.store.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("error copying settings to the portable store: %v", err)
+	}
+	return nil
+}
+
+// Reports whether a store has none of HM3k's documents
+func isEmpty(s store.SettingsStore) bool {
+	for _, name := range storeDocuments {
+		if s.Get(name) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func copyDocuments(from, to store.SettingsStore) error {
+	for _, name := range storeDocuments {
+		if document := from.Get(name); document != "" {
+			if err := to.Set(name, document); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Build the UI from the stored preferences. The settings are loaded after, once the window is up.
+func (hg *HashGenerator) start() {
+	hg.loadAppPreferences()
+	hg.makeUIcomponents()
+	hg.window.SetContent(hg.layoutUI())
+	hg.window.Canvas().Focus(hg.filterEntry)
+}
+
+// Ask for the passphrase of an encrypted file store, before anything else is loaded
+func (hg *HashGenerator) unlockStore() {
+	hg.askUnlockPassphrase(func(passphrase string) (func(), error) {
+		opened, err := hg.newStore(hg.storeConfig, passphrase)
+		return func() {
+			hg.store = opened
+			hg.start()
+			hg.loadSettings()
+		}, err
+	})
+}
+
+// Choose where the settings are kept
+func (hg *HashGenerator) editStore() {
+	typeSelect := widget.NewSelect(labelsFor(storeLabels, store.Types), nil)
+	pathEntry := widget.NewEntry()
+	pathEntry.SetPlaceHolder("e.g. /media/usb/hm3k.json")
+	pathEntry.SetText(hg.storeConfig.Path)
+	passphraseEntry := widget.NewPasswordEntry()
+	confirmEntry := widget.NewPasswordEntry()
+	explanation := widget.NewLabel("The current settings are copied to the new store, unless it already has some. " +
+		"An encrypted file's passphrase will be needed every time HM3k starts. It can't be recovered if it's forgotten.")
+	explanation.Wrapping = fyne.TextWrapWord
+
+	typeSelect.OnChanged = func(label string) {
+		config := store.Config{Type: idFor(storeLabels, label)}
+		if config.Type == store.TypePreferences {
+			pathEntry.Disable()
+		} else {
+			pathEntry.Enable()
+		}
+		if config.Encrypted() {
+			passphraseEntry.Enable()
+			confirmEntry.Enable()
+		} else {
+			passphraseEntry.Disable()
+			confirmEntry.Disable()
+		}
+	}
+	typeSelect.SetSelected(labelFor(storeLabels, withDefault(hg.storeConfig.Type, store.TypePreferences)))
+
+	d := dialog.NewForm("Settings Storage", "Use", "Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("", explanation),
+			widget.NewFormItem("Store", typeSelect),
+			widget.NewFormItem("File", pathEntry),
+			widget.NewFormItem("Passphrase", passphraseEntry),
+			widget.NewFormItem("Confirm", confirmEntry),
+		},
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			config := store.Config{Type: idFor(storeLabels, typeSelect.Selected)}
+			if config.Type != store.TypePreferences {
+				config.Path = strings.TrimSpace(pathEntry.Text)
+			}
+			if err := config.Check(); err != nil {
+				dialog.ShowError(err, hg.window)
+				return
+			}
+			if config.Encrypted() && (passphraseEntry.Text == "" || passphraseEntry.Text != confirmEntry.Text) {
+				dialog.ShowError(fmt.Errorf("enter the same passphrase twice"), hg.window)
+				return
+			}
+			hg.switchStore(config, passphraseEntry.Text)
+		}, hg.window)
+	hg.showWide(d)
+}
+
+// Open the new store and use it from now on, filling it from the current one if it's empty.
+// Settings already in it can't be loaded over the ones on the form, so nothing is saved until HM3k restarts.
+func (hg *HashGenerator) switchStore(config store.Config, passphrase string) {
+	go func() {
+		opened, err := hg.newStore(config, passphrase)
+		fyne.Do(func() {
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("error opening settings store: %v", err), hg.window)
+				return
+			}
+
+			copied := isEmpty(opened)
+			if copied {
+				if err = copyDocuments(hg.store, opened); err == nil {
+					err = opened.Flush()
+				}
+				if err != nil {
+					dialog.ShowError(fmt.Errorf("error copying settings: %v", err), hg.window)
+					return
+				}
+			}
+
+			data, err := json.Marshal(config)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("error encoding store configuration: %v", err), hg.window)
+				return
+			}
+			hg.app.Preferences().SetString(storeConfigKey, string(data))
+			hg.store = opened
+			hg.storeConfig = config
+			hg.updateVaultButton()
+
+			if copied {
+				dialog.ShowInformation("Settings Storage", "The settings have been copied, and are saved to the new store from now on.", hg.window)
+				return
+			}
+			hg.markReadOnly(fmt.Errorf("the new store already has settings"))
+			hg.disableEditing()
+			dialog.ShowInformation("Settings Storage",
+				"The settings already in the new store will be used when HM3k restarts. Nothing will be saved until then.", hg.window)
+		})
+	}()
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"HashMaster3000/derive"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Set the current identity's master verifiers, and show whether there are any on the button
+func (hg *HashGenerator) setVerifiers(verifiers []derive.Verifier) {
+	if hg.identity == "" {
+		hg.appPrefs.Verifiers = verifiers
+	}
+	for i := range hg.appPrefs.Identities {
+		if hg.appPrefs.Identities[i].Name == hg.identity {
+			hg.appPrefs.Identities[i].Verifiers = verifiers
+		}
+	}
+	hg.saveAppPreferences()
+	if len(verifiers) > 0 {
+		hg.verifierButton.Importance = widget.HighImportance
+	} else {
+		hg.verifierButton.Importance = widget.MediumImportance
+	}
+	hg.verifierButton.Refresh()
+}
+
+// Reports whether the master matches one of the verifiers, or there aren't any to check against.
+// This is slow, so it's called off the UI thread with a copy of the verifiers.
+func masterVerified(verifiers []derive.Verifier, master string) bool {
+	if len(verifiers) == 0 {
+		return true
+	}
+	for _, verifier := range verifiers {
+		if verifier.Matches(master) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add and remove the current identity's master verifiers. Verifiers are opt-in, since even a short tag is something stored about the master.
+func (hg *HashGenerator) editVerifiers() {
+	list := container.NewVBox()
+	var refresh func()
+	refresh = func() {
+		list.RemoveAll()
+		if len(hg.verifiers()) == 0 {
+			list.Add(widget.NewLabel("No verifiers, the master pass isn't checked."))
+		}
+		for i, verifier := range hg.verifiers() {
+			removeButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+				hg.setVerifiers(slices.Delete(slices.Clone(hg.verifiers()), i, i+1))
+				refresh()
+			})
+			list.Add(container.NewBorder(nil, nil, nil, removeButton, widget.NewLabel(verifier.Name)))
+		}
+	}
+	refresh()
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Name")
+	var addButton *widget.Button
+	addButton = widget.NewButton("Add Current Master", func() {
+		if hg.masterPassEntry.Validate() != nil {
+			dialog.ShowInformation("Master Pass", "Enter the master pass first.", hg.window)
+			return
+		}
+		name := nameEntry.Text
+		if name == "" {
+			name = fmt.Sprintf("Master %d", len(hg.verifiers())+1)
+		}
+		master := hg.masterPassEntry.Text
+		addButton.Disable()
+		go func() {
+			verifier, err := derive.NewVerifier(name, master)
+			fyne.Do(func() {
+				addButton.Enable()
+				if err != nil {
+					dialog.ShowError(fmt.Errorf("failed to make verifier: %v", err), hg.window)
+					return
+				}
+				hg.setVerifiers(append(slices.Clone(hg.verifiers()), verifier))
+				nameEntry.SetText("")
+				refresh()
+			})
+		}()
+	})
+
+	explanation := widget.NewLabel("A verifier is a short, slow hash of a master pass. Generating warns when the master pass doesn't match any of them.")
+	explanation.Wrapping = fyne.TextWrapWord
+	content := container.NewVBox(
+		explanation,
+		list,
+		widget.NewSeparator(),
+		container.NewBorder(nil, nil, nil, addButton, nameEntry),
+	)
+	d := dialog.NewCustom("Master Verifiers: "+identityLabel(hg.identity), "Close", content, hg.window)
+	hg.showWide(d)
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package cryptnos
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+
+	"HashMaster3000/derive"
+)
+
+// A setting that was left out of an export because Cryptnos can't represent it
+type Rejection struct {
+	Description string
+	Reason      string
+}
+
+// An exported setting that belongs to one of HM3k's named identities. Cryptnos has a single master,
+// so it only generates the same password when it's given that identity's master instead.
+type IdentityNote struct {
+	Description string
+	Identity    string
+}
+
+// What Export did with the settings, sorted by description
+type Report struct {
+	Exported   int
+	Rejected   []Rejection
+	Identities []IdentityNote
+}
+
+// Export writes the settings Cryptnos can represent as an encrypted Cryptnos export.
+// The rest are left out, and reported back along with the exported settings that need a different master.
+func Export(w io.Writer, settings map[string]derive.SavedSetting, passphrase string) (Report, error) {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	export := exportFile{
+		Namespace: xmlNamespace,
+		Version:   formatVersion,
+		Generator: "Hash Master 3000",
+	}
+	report := Report{}
+	for _, key := range keys {
+		site, err := fromSetting(settings[key])
+		if err != nil {
+			report.Rejected = append(report.Rejected, Rejection{Description: key, Reason: err.Error()})
+			continue
+		}
+		export.Sites = append(export.Sites, site)
+		if identity := settings[key].Identity; identity != "" {
+			report.Identities = append(report.Identities, IdentityNote{Description: key, Identity: identity})
+		}
+	}
+	export.SiteCount = len(export.Sites)
+	report.Exported = export.SiteCount
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte(xml.Header))
+	if err != nil {
+		return Report{}, err
+	}
+	err = xml.NewEncoder(gz).Encode(export)
+	if err != nil {
+		return Report{}, err
+	}
+	err = gz.Close()
+	if err != nil {
+		return Report{}, err
+	}
+
+	ciphertext, err := encrypt(compressed.Bytes(), passphrase)
+	if err != nil {
+		return Report{}, err
+	}
+	_, err = w.Write(ciphertext)
+	if err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+func encrypt(plaintext []byte, passphrase string) ([]byte, error) {
+	key, iv, err := keyAndIV(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// PKCS#7 padding, always at least one byte
+	pad := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append(slices.Clone(plaintext), bytes.Repeat([]byte{byte(pad)}, pad)...)
+
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+	return ciphertext, nil
+}
+
+// Map a setting to a Cryptnos site, or explain why it can't be
+func fromSetting(setting derive.SavedSetting) (exportSite, error) {
+	if setting.Scheme != "" && setting.Scheme != derive.SchemeConcatenate {
+		return exportSite{}, fmt.Errorf("Cryptnos has no %s scheme", setting.Scheme)
+	}
+	if setting.Encoding != "" && setting.Encoding != derive.EncodingBase64 {
+		return exportSite{}, fmt.Errorf("Cryptnos has no %s encoding", setting.Encoding)
+	}
+	hash := slices.IndexFunc(hashNames, func(h hashName) bool {
+		return h.algorithm == setting.Algorithm
+	})
+	if hash < 0 {
+		return exportSite{}, fmt.Errorf("Cryptnos has no %s algorithm", setting.Algorithm)
+	}
+	charType := slices.Index(charTypes, setting.CharRestrictions)
+	if charType < 0 {
+		return exportSite{}, fmt.Errorf("Cryptnos has no '%s' character type", setting.CharRestrictions)
+	}
+
+	if setting.Counter != 0 {
+		return exportSite{}, fmt.Errorf("Cryptnos has no rotation counter")
+	}
+	if setting.Policy.Active() {
+		return exportSite{}, fmt.Errorf("Cryptnos has no composition rules")
+	}
+
+	if setting.Iterations < 1 {
+		return exportSite{}, fmt.Errorf("Cryptnos needs at least one iteration, not %d", setting.Iterations)
+	}
+
+	// Cryptnos uses -1 for no length limit
+	charLimit := -1
+	if setting.Length < 0 {
+		return exportSite{}, fmt.Errorf("invalid length: %d", setting.Length)
+	}
+	if setting.Length > 0 {
+		charLimit = setting.Length
+	}
+
+	return exportSite{
+		SiteToken:  setting.Description,
+		Hash:       hashNames[hash].name,
+		Iterations: setting.Iterations,
+		CharTypes:  charType,
+		CharLimit:  charLimit,
+	}, nil
+}
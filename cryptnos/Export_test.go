@@ -0,0 +1,80 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package cryptnos
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"HashMaster3000/derive"
+)
+
+func TestExportReport(t *testing.T) {
+	cryptnosSetting := func(description string) derive.SavedSetting {
+		return derive.SavedSetting{Description: description, Algorithm: derive.AlgorithmSHA256,
+			CharRestrictions: derive.RestrictAlnumUnderscore, Length: 12, Iterations: 1}
+	}
+	with := func(description string, change func(*derive.SavedSetting)) derive.SavedSetting {
+		setting := cryptnosSetting(description)
+		change(&setting)
+		return setting
+	}
+	settings := map[string]derive.SavedSetting{
+		"plain":       cryptnosSetting("plain"),
+		"work":        with("work", func(s *derive.SavedSetting) { s.Identity = "work" }),
+		"argon2id":    with("argon2id", func(s *derive.SavedSetting) { s.Scheme = derive.SchemeArgon2id }),
+		"concatenate": with("concatenate", func(s *derive.SavedSetting) { s.Scheme = derive.SchemeConcatenate }),
+		"hex":         with("hex", func(s *derive.SavedSetting) { s.Encoding = derive.EncodingHex }),
+		"sha3":        with("sha3", func(s *derive.SavedSetting) { s.Algorithm = derive.AlgorithmSHA3_256 }),
+		"exact":       with("exact", func(s *derive.SavedSetting) { s.CharRestrictions = derive.RestrictUniformAlnum }),
+		"rotated":     with("rotated", func(s *derive.SavedSetting) { s.Counter = 1 }),
+		"rules":       with("rules", func(s *derive.SavedSetting) { s.Policy = derive.Policy{MinDigit: 1} }),
+		"inactive":    with("inactive", func(s *derive.SavedSetting) { s.Iterations = 0 }),
+	}
+
+	var export bytes.Buffer
+	report, err := Export(&export, settings, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Report{
+		Exported: 3,
+		Rejected: []Rejection{
+			{"argon2id", "Cryptnos has no argon2id scheme"},
+			{"exact", "Cryptnos has no 'exact-alnum' character type"},
+			{"hex", "Cryptnos has no hex encoding"},
+			{"inactive", "Cryptnos needs at least one iteration, not 0"},
+			{"rotated", "Cryptnos has no rotation counter"},
+			{"rules", "Cryptnos has no composition rules"},
+			{"sha3", "Cryptnos has no sha3-256 algorithm"},
+		},
+		Identities: []IdentityNote{{"work", "work"}},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("got %+v\nwant %+v", report, want)
+	}
+
+	imported, err := Import(&export, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, description := range []string{"plain", "work", "concatenate"} {
+		if _, exists := imported[description]; !exists {
+			t.Errorf("%s wasn't exported", description)
+		}
+	}
+}
+
+// No length restriction is written as Cryptnos's -1
+func TestExportNoLength(t *testing.T) {
+	site, err := fromSetting(derive.SavedSetting{Description: "a", Algorithm: derive.AlgorithmMD5, CharRestrictions: derive.RestrictNone, Iterations: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if site.CharLimit != -1 {
+		t.Errorf("got %d, want -1", site.CharLimit)
+	}
+}
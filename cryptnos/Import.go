@@ -0,0 +1,118 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package cryptnos
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"HashMaster3000/derive"
+)
+
+// Import decrypts a Cryptnos export and maps every site in it to an HM3k setting, keyed by description
+func Import(r io.Reader, passphrase string) (map[string]derive.SavedSetting, error) {
+	ciphertext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := decrypt(ciphertext, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(plaintext))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	defer gz.Close()
+
+	var export exportFile
+	err = xml.NewDecoder(gz).Decode(&export)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Cryptnos export: %v", err)
+	}
+	if export.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported Cryptnos export version: %d", export.Version)
+	}
+
+	settings := make(map[string]derive.SavedSetting, len(export.Sites))
+	for _, site := range export.Sites {
+		setting, err := toSetting(site)
+		if err != nil {
+			return nil, err
+		}
+		settings[setting.Description] = setting
+	}
+	return settings, nil
+}
+
+func decrypt(ciphertext []byte, passphrase string) ([]byte, error) {
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, ErrDecrypt
+	}
+
+	key, iv, err := keyAndIV(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	// Strip the PKCS#7 padding. Bad padding is how a wrong passphrase usually shows up
+	pad := int(plaintext[len(plaintext)-1])
+	if pad < 1 || pad > aes.BlockSize {
+		return nil, ErrDecrypt
+	}
+	for _, b := range plaintext[len(plaintext)-pad:] {
+		if int(b) != pad {
+			return nil, ErrDecrypt
+		}
+	}
+	return plaintext[:len(plaintext)-pad], nil
+}
+
+func toSetting(site exportSite) (derive.SavedSetting, error) {
+	if site.SiteToken == "" {
+		return derive.SavedSetting{}, fmt.Errorf("Cryptnos export contains a site with no name")
+	}
+
+	algorithm := ""
+	for _, hash := range hashNames {
+		if strings.EqualFold(hash.name, site.Hash) {
+			algorithm = hash.algorithm
+			break
+		}
+	}
+	if algorithm == "" {
+		return derive.SavedSetting{}, fmt.Errorf("site '%s': %w", site.SiteToken, &derive.UnsupportedAlgorithmError{Algorithm: site.Hash})
+	}
+
+	if site.CharTypes < 0 || site.CharTypes >= len(charTypes) {
+		return derive.SavedSetting{}, fmt.Errorf("site '%s': unknown character type: %d", site.SiteToken, site.CharTypes)
+	}
+
+	// Cryptnos uses -1 (or 0) for no length limit
+	length := max(site.CharLimit, 0)
+
+	return derive.SavedSetting{
+		Description:      site.SiteToken,
+		Algorithm:        algorithm,
+		CharRestrictions: charTypes[site.CharTypes],
+		Length:           length,
+		Iterations:       site.Iterations,
+	}, nil
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package cryptnos
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"HashMaster3000/derive"
+)
+
+// An export of three sites with the passphrase "correct horse", made without this package: the salt and PBKDF2 key
+// with Python's hashlib, the gzip with Python's gzip module, and the AES-256-CBC with `openssl enc`.
+// It hasn't been checked against a real Cryptnos install.
+const independentExport = "k7iqg1206W8ONVzN2zf2YXEPcZjKo+fXDIBNbFtqXLk9CCifgiL7JREXvLAS9O8J08JBrWd2mfbvl6xZhc5j0tDNfAGwFj6YLh3TqW2n" +
+	"rleOXmFuyX4MU4va5BF3/Jt/5DWOe8t/uiPZwkiE61tlnZNoHYMarxJcF240PpNWkHic88bb/4muHJCo9gEOQ16ZvDfgSK9x9WFLRy5vPNsNPk2OOQXn" +
+	"NS675qLuUQGIbVMrg1FpNOpZkveBUNgxhmYBTC4RyES2tmBnz6ZOv+jslWXePYbqK6PNjkCznWLGxmu9G/3W9TYPV7VB9QP8uo1IAlCDOLjogTZZsaN1" +
+	"vD5dy5l8/tT7TWWCGLboQKHoLo8="
+
+func TestImportIndependentExport(t *testing.T) {
+	data, err := base64.StdEncoding.DecodeString(independentExport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Import(bytes.NewReader(data), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]derive.SavedSetting{
+		"example.com": {Description: "example.com", Algorithm: derive.AlgorithmSHA256, CharRestrictions: derive.RestrictAlnumUnderscore, Length: 12, Iterations: 2},
+		"bank":        {Description: "bank", Algorithm: derive.AlgorithmWhirlpool, CharRestrictions: derive.RestrictNumeric, Length: 8, Iterations: 10},
+		"mail":        {Description: "mail", Algorithm: derive.AlgorithmTiger, CharRestrictions: derive.RestrictNone, Iterations: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	settings := map[string]derive.SavedSetting{
+		"example.com": {Description: "example.com", Algorithm: derive.AlgorithmSHA512, CharRestrictions: derive.RestrictAlnumOmit, Length: 16, Iterations: 100},
+		"mail":        {Description: "mail", Algorithm: derive.AlgorithmRIPEMD160, CharRestrictions: derive.RestrictAlpha, Iterations: 1},
+	}
+	var export bytes.Buffer
+	report, err := Export(&export, settings, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Rejected) != 0 {
+		t.Fatalf("rejected %v", report.Rejected)
+	}
+
+	got, err := Import(&export, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, settings) {
+		t.Errorf("got %+v\nwant %+v", got, settings)
+	}
+}
+
+func TestImportWrongPassphrase(t *testing.T) {
+	data, err := base64.StdEncoding.DecodeString(independentExport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Import(bytes.NewReader(data), "battery staple"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("got %v, want %v", err, ErrDecrypt)
+	}
+}
+
+// Encrypt blocks as they are, without adding padding, so the padding can be wrong
+func encryptUnpadded(t *testing.T, plaintext []byte, passphrase string) []byte {
+	t.Helper()
+	key, iv, err := keyAndIV(passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+	return ciphertext
+}
+
+func TestImportBadPadding(t *testing.T) {
+	for name, plaintext := range map[string][]byte{
+		"zero pad":                  append(bytes.Repeat([]byte{'x'}, 15), 0),
+		"pad bigger than one block": append(bytes.Repeat([]byte{'x'}, 15), 17),
+		"pad bytes differ":          append(bytes.Repeat([]byte{'x'}, 14), 5, 2),
+	} {
+		if _, err := Import(bytes.NewReader(encryptUnpadded(t, plaintext, "pass")), "pass"); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrDecrypt)
+		}
+	}
+	for name, ciphertext := range map[string][]byte{
+		"empty":         {},
+		"partial block": bytes.Repeat([]byte{1}, 20),
+	} {
+		if _, err := Import(bytes.NewReader(ciphertext), "pass"); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrDecrypt)
+		}
+	}
+}
+
+func TestImportBadGzip(t *testing.T) {
+	ciphertext, err := encrypt([]byte("<cryptnos><version>1</version></cryptnos>"), "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Import(bytes.NewReader(ciphertext), "pass"); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("got %v, want %v", err, ErrDecrypt)
+	}
+}
+
+// An encrypted export of the given sites, written by hand so it can hold values Export never writes
+func exportOfSites(t *testing.T, sites string) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><cryptnos xmlns="http://www.cryptnos.com/">` +
+		`<version>1</version><generator>Cryptnos</generator><sites>` + sites + `</sites></cryptnos>`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := encrypt(compressed.Bytes(), "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ciphertext
+}
+
+func TestImportRejectsUnknownValues(t *testing.T) {
+	for name, test := range map[string]struct {
+		site string
+		want string
+	}{
+		"hash": {`<site><siteToken>a</siteToken><hash>SHA3-256</hash><iterations>1</iterations><charTypes>0</charTypes><charLimit>-1</charLimit></site>`,
+			"SHA3-256"},
+		"negative char type": {`<site><siteToken>a</siteToken><hash>MD5</hash><iterations>1</iterations><charTypes>-1</charTypes><charLimit>-1</charLimit></site>`,
+			"character type"},
+		"char type past the end": {`<site><siteToken>a</siteToken><hash>MD5</hash><iterations>1</iterations><charTypes>5</charTypes><charLimit>-1</charLimit></site>`,
+			"character type"},
+		"no site token": {`<site><hash>MD5</hash><iterations>1</iterations><charTypes>0</charTypes><charLimit>-1</charLimit></site>`,
+			"no name"},
+	} {
+		_, err := Import(bytes.NewReader(exportOfSites(t, test.site)), "pass")
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got %v, want an error about %s", name, err, test.want)
+		}
+	}
+}
+
+// Cryptnos writes -1 for no length limit, which HM3k stores as 0
+func TestImportNoCharLimit(t *testing.T) {
+	settings, err := Import(bytes.NewReader(exportOfSites(t,
+		`<site><siteToken>a</siteToken><hash>sha-1</hash><iterations>3</iterations><charTypes>2</charTypes><charLimit>-1</charLimit></site>`)), "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := derive.SavedSetting{Description: "a", Algorithm: derive.AlgorithmSHA1, CharRestrictions: derive.RestrictAlnumOmit, Iterations: 3}
+	if got := settings["a"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
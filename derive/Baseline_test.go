@@ -0,0 +1,89 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"regexp"
+	"testing"
+)
+
+// The derivation as it was before it moved into this package, kept verbatim (apart from the
+// algorithm and restriction names) so every setting saved back then still gives the same password
+func baselineDerive(description, master, algorithm, restriction string, iterCount, length int) string {
+	constructors := map[string]func() hash.Hash{
+		AlgorithmSHA256: sha256.New,
+		AlgorithmSHA512: sha512.New,
+		AlgorithmSHA1:   sha1.New,
+		AlgorithmMD5:    md5.New,
+		AlgorithmSHA224: sha256.New224,
+		AlgorithmSHA384: sha512.New384,
+	}
+
+	result := []byte(description + master)
+	for i := 0; i < iterCount; i++ {
+		h := constructors[algorithm]()
+		h.Write(result)
+		result = h.Sum(nil)
+	}
+	processed := base64.StdEncoding.EncodeToString(result)
+
+	switch restriction {
+	case RestrictAlnumUnderscore:
+		processed = regexp.MustCompile(`[^a-zA-Z0-9]`).ReplaceAllString(processed, "_")
+	case RestrictAlnumOmit:
+		processed = regexp.MustCompile(`[^a-zA-Z0-9]`).ReplaceAllString(processed, "")
+	case RestrictAlpha:
+		processed = regexp.MustCompile(`[^a-zA-Z]`).ReplaceAllString(processed, "")
+	case RestrictNumeric:
+		processed = regexp.MustCompile(`[^0-9]`).ReplaceAllString(processed, "")
+	}
+
+	if length > 0 && len(processed) > length {
+		processed = processed[:length]
+	}
+	return processed
+}
+
+func TestBaselineEquivalence(t *testing.T) {
+	algorithms := []string{AlgorithmSHA256, AlgorithmSHA512, AlgorithmSHA1, AlgorithmMD5, AlgorithmSHA224, AlgorithmSHA384}
+	restrictions := []string{RestrictNone, RestrictAlnumUnderscore, RestrictAlnumOmit, RestrictAlpha, RestrictNumeric}
+	inputs := []struct{ description, master string }{
+		{"example.com", "master"},
+		{"", "x"},
+		{"bank ünïcode", "pässwörd €"},
+	}
+
+	for _, algorithm := range algorithms {
+		for _, restriction := range restrictions {
+			for _, input := range inputs {
+				for _, iterations := range []int{1, 2, 10} {
+					for _, length := range []int{0, 8, 12, 100} {
+						setting := SavedSetting{
+							Description:      input.description,
+							Algorithm:        algorithm,
+							CharRestrictions: restriction,
+							Length:           length,
+							Iterations:       iterations,
+						}
+						got, err := Derive(setting, input.master)
+						if err != nil {
+							t.Fatalf("%+v: %v", setting, err)
+						}
+						want := baselineDerive(input.description, input.master, algorithm, restriction, iterations, length)
+						if got != want {
+							t.Errorf("%+v: got %q, want %q", setting, got, want)
+						}
+					}
+				}
+			}
+		}
+	}
+}
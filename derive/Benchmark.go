@@ -0,0 +1,60 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	"context"
+	"time"
+)
+
+// The measured speed of a derivation on this device
+type Rate struct {
+	PerIteration time.Duration `json:"per_iteration"` // zero for scrypt, which has no time cost
+	Fixed        time.Duration `json:"fixed"`         // the part that doesn't depend on Iterations
+}
+
+// The expected time for a derivation with the given number of iterations
+func (r Rate) Estimate(iterations int) time.Duration {
+	return r.Fixed + time.Duration(iterations)*r.PerIteration
+}
+
+// The most iterations that fit in the budget, or 0 if the iterations don't affect the time
+func (r Rate) IterationsWithin(budget time.Duration) int {
+	if r.PerIteration <= 0 {
+		return 0
+	}
+	return max(1, int((budget-r.Fixed)/r.PerIteration))
+}
+
+// Each measurement runs for at least this long, to smooth over timer resolution and scheduling noise
+const minSampleTime = 100 * time.Millisecond
+
+// Measure times derivations with the scheme, algorithm and memory parameters of a setting
+// (the rest of it is ignored), doubling the iterations until a run is long enough to be representative.
+func Measure(ctx context.Context, setting SavedSetting) (Rate, error) {
+	sample := SavedSetting{
+		Description: "benchmark",
+		Scheme:      setting.Scheme,
+		Algorithm:   setting.Algorithm,
+		Memory:      setting.Memory,
+		Parallelism: setting.Parallelism,
+	}
+	for iterations := 1; ; iterations *= 2 {
+		sample.Iterations = iterations
+		start := time.Now()
+		_, err := DeriveContext(ctx, sample, "benchmark", nil)
+		elapsed := time.Since(start)
+		if err != nil {
+			return Rate{}, err
+		}
+
+		if sample.Scheme == SchemeScrypt {
+			return Rate{Fixed: elapsed}, nil
+		}
+		if elapsed >= minSampleTime {
+			return Rate{PerIteration: elapsed / time.Duration(iterations)}, nil
+		}
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+// Package derive implements the HM3k password derivation without any UI dependencies,
+// so the exact same algorithm can be embedded in other tools.
+package derive
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha3"
+	"crypto/sha512"
+	"hash"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/cxmcc/tiger"
+	"github.com/jzelinskie/whirlpool"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// The parameters that (along with the master password) determine a generated password
+type SavedSetting struct {
+	Description      string            `json:"description"`
+	Algorithm        string            `json:"algorithm"`
+	CharRestrictions string            `json:"char_restrictions"`
+	Length           int               `json:"length"`     // 0 for no length restriction
+	Iterations       int               `json:"iterations"` // 0 or less marks an inactive setting
+	Scheme           string            `json:"scheme,omitempty"`
+	Memory           int               `json:"memory,omitempty"`      // KiB, for the memory-hard schemes
+	Parallelism      int               `json:"parallelism,omitempty"` // for the memory-hard schemes
+	Encoding         string            `json:"encoding,omitempty"`
+	Policy           Policy            `json:"policy,omitzero"`
+	Custom           CustomCharset     `json:"custom_charset,omitzero"` // only for the Custom restriction
+	Passphrase       PassphraseOptions `json:"passphrase,omitzero"`     // only for the Passphrase restriction
+	Counter          int               `json:"counter,omitempty"`       // bumped each time the password is rotated
+	History          []SavedSetting    `json:"history,omitempty"`       // the parameters before each rotation, oldest first
+	Identity         string            `json:"identity,omitempty"`      // which master it's generated with, empty for the default
+}
+
+// Stable identifiers of the hash algorithms, as stored in settings (the UI has its own labels for them)
+const (
+	AlgorithmSHA256    = "sha256"
+	AlgorithmSHA512    = "sha512"
+	AlgorithmSHA1      = "sha1"
+	AlgorithmMD5       = "md5"
+	AlgorithmSHA224    = "sha224"
+	AlgorithmSHA384    = "sha384"
+	AlgorithmSHA3_224  = "sha3-224"
+	AlgorithmSHA3_256  = "sha3-256"
+	AlgorithmSHA3_384  = "sha3-384"
+	AlgorithmSHA3_512  = "sha3-512"
+	AlgorithmSHAKE128  = "shake128"
+	AlgorithmSHAKE256  = "shake256"
+	AlgorithmRIPEMD160 = "ripemd160"
+	AlgorithmWhirlpool = "whirlpool"
+	AlgorithmTiger     = "tiger"
+)
+
+// The hash algorithms implemented by getHash, in the order they're offered in the UI
+var Algorithms = []string{
+	AlgorithmSHA256,
+	AlgorithmSHA512,
+	AlgorithmSHA1,
+	AlgorithmMD5,
+	AlgorithmSHA224,
+	AlgorithmSHA384,
+	AlgorithmSHA3_224,
+	AlgorithmSHA3_256,
+	AlgorithmSHA3_384,
+	AlgorithmSHA3_512,
+	AlgorithmSHAKE128,
+	AlgorithmSHAKE256,
+	AlgorithmRIPEMD160,
+	AlgorithmWhirlpool,
+	AlgorithmTiger,
+}
+
+// Extendable-output functions, and the output size used for all but the final iteration
+var xofSizes = map[string]int{
+	AlgorithmSHAKE128: 32,
+	AlgorithmSHAKE256: 64,
+}
+
+// Give up squeezing an extendable-output function if the restrictions still leave it short after this many bytes
+const maxXOFOutput = 4096
+
+// Derive generates the password for a setting from the master password.
+// A zero Length means no length restriction, except for the exact length
+// and passphrase restrictions, which need one (for passphrases it counts words).
+func Derive(setting SavedSetting, master string) (string, error) {
+	return DeriveContext(context.Background(), setting, master, nil)
+}
+
+// DeriveContext is Derive for slow settings. It gives up with ctx's error once ctx is done,
+// and reports progress through the iterations if progress isn't nil.
+// The memory-hard schemes can't report progress, and are abandoned rather than stopped when cancelled.
+// Only one memory-hard derivation runs at a time, so a new one waits for any that was abandoned.
+func DeriveContext(ctx context.Context, setting SavedSetting, master string, progress Progress) (string, error) {
+	iterCount := setting.Iterations
+	if iterCount < 1 {
+		return "", &InvalidIterationsError{Value: iterCount}
+	}
+
+	length := setting.Length
+	if length < 0 {
+		return "", &InvalidLengthError{Value: length}
+	}
+
+	encode, err := getEncoder(setting.Encoding)
+	if err != nil {
+		return "", err
+	}
+
+	// The exact length restrictions map the raw output, so they need to know the length up front
+	alphabet, uniform := uniformAlphabets[setting.CharRestrictions]
+	passphrase := setting.CharRestrictions == RestrictPassphrase
+	if (uniform || passphrase) && length == 0 {
+		return "", &LengthRequiredError{Restriction: setting.CharRestrictions}
+	}
+
+	if setting.CharRestrictions == RestrictCustom && setting.Custom.Alphabet == "" {
+		return "", &InvalidParameterError{Parameter: "custom alphabet", Value: setting.Custom.Name}
+	}
+
+	// Encode the raw output and apply character restrictions
+	finish := func(hash []byte) string {
+		if setting.CharRestrictions == RestrictCustom {
+			return applyCustomCharset(encode(hash), setting.Custom)
+		}
+		return applyCharacterRestrictions(encode(hash), setting.CharRestrictions)
+	}
+
+	if setting.Counter < 0 {
+		return "", &InvalidParameterError{Parameter: "counter", Value: strconv.Itoa(setting.Counter)}
+	}
+	description := rotatedDescription(setting)
+
+	var hash []byte
+	switch setting.Scheme {
+	case "", SchemeConcatenate:
+		// Combine the tokens
+		combined := []byte(description + master)
+
+		if _, isXOF := xofSizes[setting.Algorithm]; isXOF && !uniform && !passphrase {
+			var processed string
+			processed, hash, err = getXOFWithIterations(ctx, combined, setting.Algorithm, iterCount, length, finish, progress)
+			if err != nil {
+				return "", err
+			}
+			return finishPolicy(applyLength(processed, length), setting.Policy, hash)
+		}
+		hash, err = getHashWithIterations(ctx, combined, setting.Algorithm, iterCount, progress)
+
+	case SchemeHMAC:
+		hash, err = getHMACWithIterations(ctx, []byte(master), []byte(description), setting.Algorithm, iterCount, progress)
+
+	case SchemePBKDF2:
+		hash, err = getPBKDF2(ctx, master, []byte(description), setting.Algorithm, iterCount, progress)
+
+	case SchemeArgon2id, SchemeScrypt:
+		var memory, parallelism int
+		memory, parallelism, err = getMemoryParams(setting)
+		if err != nil {
+			break
+		}
+		hash, err = runAbandonable(ctx, func() ([]byte, error) {
+			if setting.Scheme == SchemeArgon2id {
+				return getArgon2id(master, []byte(description), iterCount, memory, parallelism), nil
+			}
+			return getScrypt(master, []byte(description), memory, parallelism)
+		})
+
+	default:
+		err = &UnsupportedSchemeError{Scheme: setting.Scheme}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if uniform {
+		return finishPolicy(mapToAlphabet(hash, alphabet, length), setting.Policy, hash)
+	}
+	if passphrase {
+		return finishPolicy(makePassphrase(hash, length, setting.Passphrase), setting.Policy, hash)
+	}
+	return finishPolicy(applyLength(finish(hash), length), setting.Policy, hash)
+}
+
+// Enforce the composition rules last, if there are any
+func finishPolicy(processed string, policy Policy, hash []byte) (string, error) {
+	if !policy.Active() {
+		return processed, nil
+	}
+	return applyPolicy(processed, policy, hash)
+}
+
+// The description as fed to the derivation. Rotating a setting appends its counter,
+// after a separator that can't be confused with the end of the description itself.
+func rotatedDescription(setting SavedSetting) string {
+	if setting.Counter == 0 {
+		return setting.Description
+	}
+	return setting.Description + "\x00" + strconv.Itoa(setting.Counter)
+}
+
+// Apply length restriction. Length counts characters, as custom alphabets needn't be ASCII.
+func applyLength(processed string, length int) string {
+	if length > 0 && utf8.RuneCountInString(processed) > length {
+		return string([]rune(processed)[:length])
+	}
+	return processed
+}
+
+func getHashWithIterations(ctx context.Context, input []byte, algorithm string, iterCount int, progress Progress) ([]byte, error) {
+	var err error
+	result := input
+	for i := 0; i < iterCount; i++ {
+		result, err = getHash(result, algorithm)
+		if err != nil {
+			if _, ok := err.(*UnsupportedAlgorithmError); ok {
+				return nil, err
+			}
+			return nil, &IterationError{Iteration: i + 1, Err: err}
+		}
+		if err = checkpoint(ctx, progress, i+1, iterCount); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// The final iteration of an extendable-output function is squeezed until there's
+// enough output left after encoding and character restrictions to satisfy the length
+func getXOFWithIterations(ctx context.Context, input []byte, algorithm string, iterCount int, length int, finish func([]byte) string, progress Progress) (string, []byte, error) {
+	result, err := getHashWithIterations(ctx, input, algorithm, iterCount-1, progress)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var xof *sha3.SHAKE
+	switch algorithm {
+	case AlgorithmSHAKE128:
+		xof = sha3.NewSHAKE128()
+	case AlgorithmSHAKE256:
+		xof = sha3.NewSHAKE256()
+	default:
+		return "", nil, &UnsupportedAlgorithmError{Algorithm: algorithm}
+	}
+	xof.Write(result)
+
+	size := xofSizes[algorithm]
+	output := []byte{}
+	for {
+		chunk := make([]byte, size)
+		xof.Read(chunk)
+		output = append(output, chunk...)
+
+		processed := finish(output)
+		got := utf8.RuneCountInString(processed)
+		if length == 0 || got >= length {
+			return processed, output, nil
+		}
+		if len(output) >= maxXOFOutput {
+			return "", nil, &ShortOutputError{Length: length, Got: got}
+		}
+	}
+}
+
+func getHash(input []byte, algorithm string) ([]byte, error) {
+	switch algorithm {
+	case AlgorithmSHAKE128:
+		return sha3.SumSHAKE128(input, xofSizes[algorithm]), nil
+	case AlgorithmSHAKE256:
+		return sha3.SumSHAKE256(input, xofSizes[algorithm]), nil
+	}
+
+	newHash, err := hashConstructor(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	h := newHash()
+
+	_, err = h.Write(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// Returns a constructor for the named fixed-size digest, for use directly or by keyed constructions
+func hashConstructor(algorithm string) (func() hash.Hash, error) {
+	switch algorithm {
+	case AlgorithmSHA256:
+		return sha256.New, nil
+	case AlgorithmSHA512:
+		return sha512.New, nil
+	case AlgorithmSHA1:
+		return sha1.New, nil
+	case AlgorithmMD5:
+		return md5.New, nil
+	case AlgorithmSHA224:
+		return sha256.New224, nil
+	case AlgorithmSHA384:
+		return sha512.New384, nil
+	case AlgorithmSHA3_224:
+		return func() hash.Hash { return sha3.New224() }, nil
+	case AlgorithmSHA3_256:
+		return func() hash.Hash { return sha3.New256() }, nil
+	case AlgorithmSHA3_384:
+		return func() hash.Hash { return sha3.New384() }, nil
+	case AlgorithmSHA3_512:
+		return func() hash.Hash { return sha3.New512() }, nil
+	case AlgorithmRIPEMD160:
+		return ripemd160.New, nil
+	case AlgorithmWhirlpool:
+		return whirlpool.New, nil
+	case AlgorithmTiger:
+		// The original Tiger padding (not Tiger2), as used by Cryptnos
+		return tiger.New, nil
+	default:
+		return nil, &UnsupportedAlgorithmError{Algorithm: algorithm}
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+	"unicode/utf8"
+)
+
+type digestVector struct {
+	algorithm string
+	input     string
+	digest    string // hex
+}
+
+// The NIST FIPS 202 example vectors
+var sha3Vectors = []digestVector{
+	{AlgorithmSHA3_224, "", "6b4e03423667dbb73b6e15454f0eb1abd4597f9a1b078e3f5b5a6bc7"},
+	{AlgorithmSHA3_224, "abc", "e642824c3f8cf24ad09234ee7d3c766fc9a3a5168d0c94ad73b46fdf"},
+	{AlgorithmSHA3_224, "abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq", "8a24108b154ada21c9fd5574494479ba5c7e7ab76ef264ead0fcce33"},
+	{AlgorithmSHA3_256, "", "a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a"},
+	{AlgorithmSHA3_256, "abc", "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532"},
+	{AlgorithmSHA3_256, "abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq", "41c0dba2a9d6240849100376a8235e2c82e1b9998a999e21db32dd97496d3376"},
+	{AlgorithmSHA3_384, "", "0c63a75b845e4f7d01107d852e4c2485c51a50aaaa94fc61995e71bbee983a2ac3713831264adb47fb6bd1e058d5f004"},
+	{AlgorithmSHA3_384, "abc", "ec01498288516fc926459f58e2c6ad8df9b473cb0fc08c2596da7cf0e49be4b298d88cea927ac7f539f1edf228376d25"},
+	{AlgorithmSHA3_384, "abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq", "991c665755eb3a4b6bbdfb75c78a492e8c56a22c5c4d7e429bfdbc32b9d4ad5aa04a1f076e62fea19eef51acd0657c22"},
+	{AlgorithmSHA3_512, "", "a69f73cca23a9ac5c8b567dc185a756e97c982164fe25859e0d1dcc1475c80a615b2123af1f5f94c11e3e9402c3ac558f500199d95b6d3e301758586281dcd26"},
+	{AlgorithmSHA3_512, "abc", "b751850b1a57168a5693cd924b6b096e08f621827444f70d884f5d0240d2712e10e116e9192af3c91a7ec57647e3934057340b4cf408d5a56592f8274eec53f0"},
+	{AlgorithmSHA3_512, "abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq", "04a371e84ecfb5b8b77cb48610fca8182dd457ce6f326a0fd3d7ec2f1e91636dee691fbe0c985302ba1b0d8dc78c086346b533b49c030d99a27daf1139d6e75e"},
+}
+
+func testDigests(t *testing.T, vectors []digestVector) {
+	t.Helper()
+	for _, v := range vectors {
+		digest, err := getHash([]byte(v.input), v.algorithm)
+		if err != nil {
+			t.Fatalf("%s(%q): %v", v.algorithm, v.input, err)
+		}
+		if got := hex.EncodeToString(digest); got != v.digest {
+			t.Errorf("%s(%q) = %s, want %s", v.algorithm, v.input, got, v.digest)
+		}
+	}
+}
+
+func TestSHA3Digests(t *testing.T) {
+	testDigests(t, sha3Vectors)
+}
+
+type deriveVector struct {
+	setting SavedSetting
+	master  string
+	want    string
+}
+
+func testDerive(t *testing.T, vectors []deriveVector) {
+	t.Helper()
+	for _, v := range vectors {
+		got, err := Derive(v.setting, v.master)
+		if err != nil {
+			t.Fatalf("%+v: %v", v.setting, err)
+		}
+		if got != v.want {
+			t.Errorf("%+v: got %q, want %q", v.setting, got, v.want)
+		}
+	}
+}
+
+// Base64 of the iterated digest, restricted and truncated, as computed independently of this package
+func TestDeriveSHA3(t *testing.T) {
+	testDerive(t, []deriveVector{
+		{SavedSetting{Description: "example.com", Algorithm: AlgorithmSHA3_224, CharRestrictions: RestrictNone, Iterations: 1}, "master",
+			"GPqR/bniZci15dXmfwx8FPPZCwyA7U/Jxnx0XQ=="},
+		{SavedSetting{Description: "example.com", Algorithm: AlgorithmSHA3_256, CharRestrictions: RestrictAlnumUnderscore, Length: 12, Iterations: 2}, "master",
+			"vfydn25Jr_az"},
+		{SavedSetting{Description: "example.com", Algorithm: AlgorithmSHA3_384, CharRestrictions: RestrictAlnumOmit, Length: 16, Iterations: 10}, "master",
+			"POf0hDhL2tQ53uDN"},
+		{SavedSetting{Description: "example.com", Algorithm: AlgorithmSHA3_512, CharRestrictions: RestrictAlpha, Length: 20, Iterations: 1000}, "master",
+			"DhrrchlfSlTXgpHtZePF"},
+		{SavedSetting{Description: "bank", Algorithm: AlgorithmSHA3_256, CharRestrictions: RestrictNumeric, Length: 8, Iterations: 3}, "pässwörd",
+			"18166"},
+		{SavedSetting{Description: "", Algorithm: AlgorithmSHA3_512, CharRestrictions: RestrictNone, Length: 32, Iterations: 1}, "x",
+			"D9snlgMIxRRn7dSaD14MQ0ycynIfTDW/"},
+	})
+}
+
+// The published test vectors: RIPEMD-160 from its authors, Whirlpool from ISO/IEC 10118-3,
+// and Tiger from its reference implementation
+var legacyDigestVectors = []digestVector{
+	{AlgorithmRIPEMD160, "", "9c1185a5c5e9fc54612808977ee8f548b2258d31"},
+	{AlgorithmRIPEMD160, "abc", "8eb208f7e05d987a9b044a8e98c6b087f15a0bfc"},
+	{AlgorithmRIPEMD160, "message digest", "5d0689ef49d2fae572b881b123a85ffa21595f36"},
+	{AlgorithmWhirlpool, "", "19fa61d75522a4669b44e39c1d2e1726c530232130d407f89afee0964997f7a73e83be698b288febcf88e3e03c4f0757ea8964e59b63d93708b138cc42a66eb3"},
+	{AlgorithmWhirlpool, "abc", "4e2448a4c6f486bb16b6562c73b4020bf3043e3a731bce721ae1b303d97e6d4c7181eebdb6c57e277d0e34957114cbd6c797fc9d95d8b582d225292076d4eef5"},
+	{AlgorithmWhirlpool, "message digest", "378c84a4126e2dc6e56dcc7458377aac838d00032230f53ce1f5700c0ffb4d3b8421557659ef55c106b4b52ac5a4aaa692ed920052838f3362e86dbd37a8903e"},
+	{AlgorithmTiger, "", "3293ac630c13f0245f92bbb1766e16167a4e58492dde73f3"},
+	{AlgorithmTiger, "abc", "2aab1484e8c158f2bfb8c5ff41b57a525129131c957b5f93"},
+	{AlgorithmTiger, "The quick brown fox jumps over the lazy dog", "6d12a41e72e644f017b6f0e2f7b44c6285f06dd5d2c5b075"},
+}
+
+func TestLegacyDigests(t *testing.T) {
+	testDigests(t, legacyDigestVectors)
+}
+
+// Cryptnos uses the original Tiger padding. Tiger2 pads with 0x80 rather than 0x01, which changes every digest.
+func TestTigerIsNotTiger2(t *testing.T) {
+	const tiger2Empty = "4441be75f6018773c206c22745374b924aa8313fef919f41"
+	digest, err := getHash(nil, AlgorithmTiger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(digest) == tiger2Empty {
+		t.Error("Tiger is using the Tiger2 padding")
+	}
+}
+
+// A single iteration of description+master is the digest of their concatenation, so these follow
+// from the published digests above through the Base64 encoding and restrictions Cryptnos applies
+func TestDeriveLegacyAlgorithms(t *testing.T) {
+	testDerive(t, []deriveVector{
+		{SavedSetting{Description: "a", Algorithm: AlgorithmRIPEMD160, CharRestrictions: RestrictNone, Iterations: 1}, "bc",
+			"jrII9+BdmHqbBEqOmMawh/FaC/w="},
+		{SavedSetting{Description: "a", Algorithm: AlgorithmRIPEMD160, CharRestrictions: RestrictAlnumUnderscore, Length: 12, Iterations: 1}, "bc",
+			"jrII9_BdmHqb"},
+		{SavedSetting{Description: "a", Algorithm: AlgorithmWhirlpool, CharRestrictions: RestrictNone, Iterations: 1}, "bc",
+			"TiRIpMb0hrsWtlYsc7QCC/MEPjpzG85yGuGzA9l+bUxxge69tsV+J30ONJVxFMvWx5f8nZXYtYLSJSkgdtTu9Q=="},
+		{SavedSetting{Description: "The quick brown fox ", Algorithm: AlgorithmWhirlpool, CharRestrictions: RestrictAlnumUnderscore, Length: 12, Iterations: 1}, "jumps over the lazy dog",
+			"uX3lEukeOCi0"},
+		{SavedSetting{Description: "a", Algorithm: AlgorithmTiger, CharRestrictions: RestrictNone, Iterations: 1}, "bc",
+			"KqsUhOjBWPK/uMX/QbV6UlEpExyVe1+T"},
+		{SavedSetting{Description: "The quick brown fox ", Algorithm: AlgorithmTiger, CharRestrictions: RestrictAlnumUnderscore, Length: 12, Iterations: 1}, "jumps over the lazy dog",
+			"bRKkHnLmRPAX"},
+		// 100 iterations, computed with a separate RIPEMD-160 implementation
+		{SavedSetting{Description: "example.com", Algorithm: AlgorithmRIPEMD160, CharRestrictions: RestrictAlnumOmit, Length: 10, Iterations: 100}, "master",
+			"oNtZANbtib"},
+	})
+}
+
+// Length counts characters, so multibyte custom alphabets aren't cut short or split mid-character
+func TestLengthCountsCharacters(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmSHA256, AlgorithmSHAKE256} {
+		setting := SavedSetting{Description: "example.com", Algorithm: algorithm, CharRestrictions: RestrictCustom,
+			Length: 12, Iterations: 1, Custom: CustomCharset{Name: "sharp", Alphabet: "ß"}}
+		for _, replacement := range []string{"ß", "é"} {
+			setting.Custom.Replacement = replacement
+			password, err := Derive(setting, "master")
+			if err != nil {
+				t.Fatalf("%+v: %v", setting, err)
+			}
+			if !utf8.ValidString(password) || utf8.RuneCountInString(password) != 12 {
+				t.Errorf("%+v: got %q, want 12 characters", setting, password)
+			}
+		}
+	}
+}
+
+// SHAKE output is squeezed until the restrictions leave enough characters, so it always fills the length exactly
+func TestXOFFillsLength(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmSHAKE128, AlgorithmSHAKE256} {
+		for _, restriction := range []string{RestrictNumeric, RestrictAlpha, RestrictAlnumOmit, RestrictAlnumUnderscore} {
+			for _, length := range []int{1, 16, 64, 200, 500} {
+				for _, description := range []string{"example.com", "bank", ""} {
+					setting := SavedSetting{Description: description, Algorithm: algorithm, CharRestrictions: restriction, Length: length, Iterations: 2}
+					password, err := Derive(setting, "master")
+					if err != nil {
+						t.Fatalf("%+v: %v", setting, err)
+					}
+					if len(password) != length {
+						t.Errorf("%+v: got %d characters, want %d", setting, len(password), length)
+					}
+				}
+			}
+		}
+	}
+}
+
+// A custom alphabet that matches none of the output can't fill the length however much is squeezed
+func TestXOFShortOutput(t *testing.T) {
+	setting := SavedSetting{Description: "example.com", Algorithm: AlgorithmSHAKE256, CharRestrictions: RestrictCustom,
+		Length: 8, Iterations: 1, Custom: CustomCharset{Name: "none", Alphabet: "~"}}
+	_, err := Derive(setting, "master")
+	var short *ShortOutputError
+	if !errors.As(err, &short) {
+		t.Fatalf("got %v, want a ShortOutputError", err)
+	}
+	if short.Length != 8 || short.Got != 0 {
+		t.Errorf("got %+v", short)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+)
+
+// How the raw hash output is turned into text, before any character restrictions.
+// An empty Encoding is the same as EncodingBase64, so settings saved before encodings existed are unchanged.
+// These are stable identifiers, the UI has its own labels for them.
+const (
+	EncodingBase64    = "base64"
+	EncodingBase64URL = "base64url" // '-' and '_' instead of '+' and '/', no padding
+	EncodingHex       = "hex"
+	EncodingBase32    = "base32" // no padding
+	EncodingBase58    = "base58" // the Bitcoin alphabet, no look-alike characters
+	EncodingZ85       = "z85"
+)
+
+// The output encodings, in the order they're offered in the UI
+var Encodings = []string{
+	EncodingBase64,
+	EncodingBase64URL,
+	EncodingHex,
+	EncodingBase32,
+	EncodingBase58,
+	EncodingZ85,
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+const z85Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ.-:+=^!/*?&<>()[]{}@%$#"
+
+func getEncoder(encoding string) (func([]byte) string, error) {
+	switch encoding {
+	case "", EncodingBase64:
+		return base64.StdEncoding.EncodeToString, nil
+	case EncodingBase64URL:
+		return base64.RawURLEncoding.EncodeToString, nil
+	case EncodingHex:
+		return hex.EncodeToString, nil
+	case EncodingBase32:
+		return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString, nil
+	case EncodingBase58:
+		return encodeBase58, nil
+	case EncodingZ85:
+		return encodeZ85, nil
+	default:
+		return nil, &UnsupportedEncodingError{Encoding: encoding}
+	}
+}
+
+// Base58 treats the whole input as one big-endian number, with each leading zero byte written as '1'
+func encodeBase58(input []byte) string {
+	num := new(big.Int).SetBytes(input)
+	radix := big.NewInt(int64(len(base58Alphabet)))
+	mod := new(big.Int)
+
+	reversed := []byte{}
+	for num.Sign() > 0 {
+		num.DivMod(num, radix, mod)
+		reversed = append(reversed, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range input {
+		if b != 0 {
+			break
+		}
+		reversed = append(reversed, base58Alphabet[0])
+	}
+
+	output := make([]byte, len(reversed))
+	for i, c := range reversed {
+		output[len(reversed)-1-i] = c
+	}
+	return string(output)
+}
+
+// Z85 encodes each 4 bytes as 5 characters. Strict Z85 only takes whole groups of 4,
+// so a trailing partial group of n bytes is zero padded and written as n+1 characters, like Ascii85.
+func encodeZ85(input []byte) string {
+	output := []byte{}
+	for i := 0; i < len(input); i += 4 {
+		group := make([]byte, 4)
+		n := copy(group, input[i:])
+		value := binary.BigEndian.Uint32(group)
+
+		chars := make([]byte, 5)
+		for j := 4; j >= 0; j-- {
+			chars[j] = z85Alphabet[value%85]
+			value /= 85
+		}
+		output = append(output, chars[:n+1]...)
+	}
+	return string(output)
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+type encodingVector struct {
+	input string // hex
+	want  string
+}
+
+func testEncoding(t *testing.T, encode func([]byte) string, vectors []encodingVector) {
+	t.Helper()
+	for _, v := range vectors {
+		input, err := hex.DecodeString(v.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := encode(input); got != v.want {
+			t.Errorf("%s: got %q, want %q", v.input, got, v.want)
+		}
+	}
+}
+
+// The common Base58 vectors, including those from Bitcoin Core's tests for leading zero bytes
+func TestBase58(t *testing.T) {
+	testEncoding(t, encodeBase58, []encodingVector{
+		{"", ""},
+		{"48656c6c6f20576f726c6421", "2NEpo7TZRRrLZSi2U"}, // "Hello World!"
+		{"00", "1"},
+		{"000000", "111"},
+		{"0000287fb4cd", "11233QC4"},
+		{"00eb15231dfceb60925886b67d065299925915aeb172c06647", "1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE9L"},
+	})
+}
+
+// The Z85 specification's example, then the partial groups it doesn't cover
+func TestZ85(t *testing.T) {
+	testEncoding(t, encodeZ85, []encodingVector{
+		{"", ""},
+		{"864FD26FB559F75B", "HelloWorld"},
+		{"864FD26FB559", "HelloWoi"},
+		{"86", "H5"},
+	})
+}
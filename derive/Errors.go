@@ -0,0 +1,116 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import "fmt"
+
+// Returned when a setting names a hash algorithm that isn't implemented
+type UnsupportedAlgorithmError struct {
+	Algorithm string
+}
+
+func (e *UnsupportedAlgorithmError) Error() string {
+	return fmt.Sprintf("unsupported algorithm: %s", e.Algorithm)
+}
+
+// Returned when a setting names a derivation scheme that isn't implemented
+type UnsupportedSchemeError struct {
+	Scheme string
+}
+
+func (e *UnsupportedSchemeError) Error() string {
+	return fmt.Sprintf("unsupported scheme: %s", e.Scheme)
+}
+
+// Returned when a setting names an output encoding that isn't implemented
+type UnsupportedEncodingError struct {
+	Encoding string
+}
+
+func (e *UnsupportedEncodingError) Error() string {
+	return fmt.Sprintf("unsupported encoding: %s", e.Encoding)
+}
+
+// Returned when a scheme can't be used with the chosen algorithm
+type IncompatibleAlgorithmError struct {
+	Scheme    string
+	Algorithm string
+}
+
+func (e *IncompatibleAlgorithmError) Error() string {
+	return fmt.Sprintf("%s scheme can't be used with %s", e.Scheme, e.Algorithm)
+}
+
+// Returned when the iteration count isn't a positive integer
+type InvalidIterationsError struct {
+	Value int
+}
+
+func (e *InvalidIterationsError) Error() string {
+	return fmt.Sprintf("invalid iteration count: %d", e.Value)
+}
+
+// Returned when the length is negative
+type InvalidLengthError struct {
+	Value int
+}
+
+func (e *InvalidLengthError) Error() string {
+	return fmt.Sprintf("invalid length: %d", e.Value)
+}
+
+// Returned when a restriction that produces an exact length is used without one
+type LengthRequiredError struct {
+	Restriction string
+}
+
+func (e *LengthRequiredError) Error() string {
+	return fmt.Sprintf("'%s' needs a length", e.Restriction)
+}
+
+// Returned when a composition policy needs more characters than the password has
+type PolicyError struct {
+	Policy Policy
+	Length int
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("can't fit rules (%s) into %d characters", e.Policy, e.Length)
+}
+
+// Returned when the restrictions leave fewer characters than the length, even after an extendable-output
+// function has been squeezed as far as it's allowed (e.g. a custom alphabet that matches none of the output)
+type ShortOutputError struct {
+	Length int
+	Got    int
+}
+
+func (e *ShortOutputError) Error() string {
+	return fmt.Sprintf("the restrictions only left %d of the %d characters needed", e.Got, e.Length)
+}
+
+// Returned when a scheme specific parameter is out of range
+type InvalidParameterError struct {
+	Parameter string
+	Value     string
+}
+
+func (e *InvalidParameterError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Parameter, e.Value)
+}
+
+// Wraps an error from the underlying hash during one of the iterations
+type IterationError struct {
+	Iteration int
+	Err       error
+}
+
+func (e *IterationError) Error() string {
+	return fmt.Sprintf("iteration %d failed: %v", e.Iteration, e.Err)
+}
+
+func (e *IterationError) Unwrap() error {
+	return e.Err
+}
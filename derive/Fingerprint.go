@@ -0,0 +1,35 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// The fingerprint is a slow hash so that seeing it doesn't make guessing the master cheap,
+// but quick enough to keep up with typing
+const (
+	fingerprintTime        = 2
+	fingerprintMemory      = 32 * 1024 // KiB
+	fingerprintParallelism = 1
+	fingerprintSalt        = "HM3k master fingerprint"
+	fingerprintWords       = 2
+)
+
+// Fingerprint returns a few words that are always the same for the same master password,
+// so a typo shows up as unfamiliar words before any password is generated from it
+func Fingerprint(master string) string {
+	hash := argon2.IDKey([]byte(master), []byte(fingerprintSalt), fingerprintTime, fingerprintMemory, fingerprintParallelism, kdfKeyLength)
+	stream := newDomainStream("fingerprint", hash)
+
+	words := wordlist()
+	chosen := make([]string, fingerprintWords)
+	for i := range chosen {
+		chosen[i] = words[uniformIndex(stream, len(words))]
+	}
+	return strings.Join(chosen, " ")
+}
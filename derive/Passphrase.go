@@ -0,0 +1,56 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	_ "embed"
+	"strings"
+	"sync"
+)
+
+// Turns the hash output into words rather than characters. Length is the number of words,
+// and the formatting is in the setting's Passphrase field.
+const RestrictPassphrase = "passphrase"
+
+// How the words of a passphrase are put together
+type PassphraseOptions struct {
+	Separator  string `json:"separator,omitempty"`
+	Capitalise bool   `json:"capitalise,omitempty"` // capitalise the first letter of each word
+	Digit      bool   `json:"digit,omitempty"`      // add a digit to the end of one of the words
+}
+
+// The EFF large wordlist (https://www.eff.org/dice), one "dice-roll<TAB>word" per line
+//
+//go:embed eff_large_wordlist.txt
+var effLargeWordlist string
+
+var wordlist = sync.OnceValue(func() []string {
+	words := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(effLargeWordlist), "\n") {
+		_, word, _ := strings.Cut(line, "\t")
+		words = append(words, strings.TrimSpace(word))
+	}
+	return words
+})
+
+// Pick words from the whole wordlist with equal likelihood, then apply the formatting options
+func makePassphrase(hash []byte, numWords int, options PassphraseOptions) string {
+	words := wordlist()
+	stream := newByteStream(hash)
+
+	chosen := make([]string, numWords)
+	for i := range chosen {
+		chosen[i] = words[uniformIndex(stream, len(words))]
+		if options.Capitalise {
+			chosen[i] = strings.ToUpper(chosen[i][:1]) + chosen[i][1:]
+		}
+	}
+	if options.Digit {
+		i := uniformIndex(stream, numWords)
+		chosen[i] += string(digitChars[uniformIndex(stream, len(digitChars))])
+	}
+
+	return strings.Join(chosen, options.Separator)
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	"crypto/sha256"
+	"slices"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// Computed with a separate implementation, which picks the words, the word for the digit and the digit
+// from the digest and its SHAKE256 stream, rejecting values from the incomplete range at the top
+func TestDerivePassphrase(t *testing.T) {
+	testDerive(t, []deriveVector{
+		{SavedSetting{Description: "example.com", Algorithm: AlgorithmSHA256, CharRestrictions: RestrictPassphrase, Length: 5, Iterations: 1,
+			Passphrase: PassphraseOptions{Separator: "-", Capitalise: true, Digit: true}}, "master",
+			"Brick-Ditzy-Sixfold-Boneless-Trowel8"},
+	})
+}
+
+func TestPassphraseWords(t *testing.T) {
+	hash := sha256.Sum256([]byte("example.commaster"))
+	for _, numWords := range []int{1, 4, 10} {
+		plain := strings.Split(makePassphrase(hash[:], numWords, PassphraseOptions{Separator: " "}), " ")
+		if len(plain) != numWords {
+			t.Fatalf("got %d words, want %d", len(plain), numWords)
+		}
+		for _, word := range plain {
+			if !slices.Contains(wordlist(), word) {
+				t.Errorf("%q isn't in the wordlist", word)
+			}
+		}
+
+		// The options only change how the same words are put together
+		for _, separator := range []string{"", "-", ".", " + "} {
+			if got, want := makePassphrase(hash[:], numWords, PassphraseOptions{Separator: separator}), strings.Join(plain, separator); got != want {
+				t.Errorf("separator %q: got %q, want %q", separator, got, want)
+			}
+		}
+
+		capitalised := strings.Split(makePassphrase(hash[:], numWords, PassphraseOptions{Separator: " ", Capitalise: true}), " ")
+		for i, word := range capitalised {
+			if !unicode.IsUpper(rune(word[0])) || word[1:] != plain[i][1:] || !strings.EqualFold(word, plain[i]) {
+				t.Errorf("got %q, want %q capitalised", word, plain[i])
+			}
+		}
+
+		withDigit := strings.Split(makePassphrase(hash[:], numWords, PassphraseOptions{Separator: " ", Digit: true}), " ")
+		digits := 0
+		for i, word := range withDigit {
+			if word == plain[i] {
+				continue
+			}
+			digits++
+			if len(word) != len(plain[i])+1 || !strings.HasPrefix(word, plain[i]) || !unicode.IsDigit(rune(word[len(word)-1])) {
+				t.Errorf("got %q, want %q with a digit on the end", word, plain[i])
+			}
+		}
+		if digits != 1 {
+			t.Errorf("%q: got a digit on %d words, want 1", withDigit, digits)
+		}
+	}
+}
+
+// The digit goes on different words for different hashes, not always the same one
+func TestPassphraseDigitPlacement(t *testing.T) {
+	const numWords = 4
+	placed := map[int]bool{}
+	for _, description := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o", "p"} {
+		hash := sha256.Sum256([]byte(description + "master"))
+		words := strings.Split(makePassphrase(hash[:], numWords, PassphraseOptions{Separator: " ", Digit: true}), " ")
+		for i, word := range words {
+			if unicode.IsDigit(rune(word[len(word)-1])) {
+				placed[i] = true
+			}
+		}
+	}
+	if len(placed) < 2 {
+		t.Errorf("the digit only ever went on words %v", placed)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Composition rules for sites that insist on a mix of character classes.
+// They're satisfied deterministically, after the restrictions and length have been applied.
+type Policy struct {
+	MinUpper  int    `json:"min_upper,omitempty"`
+	MinLower  int    `json:"min_lower,omitempty"`
+	MinDigit  int    `json:"min_digit,omitempty"`
+	MinSymbol int    `json:"min_symbol,omitempty"`
+	Symbols   string `json:"symbols,omitempty"` // the only symbols allowed, if set
+}
+
+// The symbols used to satisfy MinSymbol when the policy doesn't name its own
+const defaultSymbols = "!#$%&*+-=?@^_"
+
+// Reports whether the policy has any rules to enforce
+func (p Policy) Active() bool {
+	return p.MinUpper > 0 || p.MinLower > 0 || p.MinDigit > 0 || p.MinSymbol > 0 || p.Symbols != ""
+}
+
+func (p Policy) String() string {
+	if !p.Active() {
+		return "none"
+	}
+	rules := []string{}
+	for _, rule := range []struct {
+		min  int
+		name string
+	}{{p.MinUpper, "upper"}, {p.MinLower, "lower"}, {p.MinDigit, "digit"}, {p.MinSymbol, "symbol"}} {
+		if rule.min > 0 {
+			rules = append(rules, fmt.Sprintf("%d+ %s", rule.min, rule.name))
+		}
+	}
+	if p.Symbols != "" {
+		rules = append(rules, "symbols "+p.Symbols)
+	}
+	return strings.Join(rules, ", ")
+}
+
+// Which characters each class draws from and how many it needs
+type charClass struct {
+	chars    []rune
+	min      int
+	contains func(c rune) bool
+}
+
+func (p Policy) classes() []charClass {
+	symbols := p.Symbols
+	if symbols == "" {
+		symbols = defaultSymbols
+	}
+	isAlnum := func(c rune) bool {
+		return strings.ContainsRune(upperChars+lowerChars+digitChars, c)
+	}
+	return []charClass{
+		{[]rune(upperChars), p.MinUpper, func(c rune) bool { return strings.ContainsRune(upperChars, c) }},
+		{[]rune(lowerChars), p.MinLower, func(c rune) bool { return strings.ContainsRune(lowerChars, c) }},
+		{[]rune(digitChars), p.MinDigit, func(c rune) bool { return strings.ContainsRune(digitChars, c) }},
+		{[]rune(symbols), p.MinSymbol, func(c rune) bool {
+			if p.Symbols == "" {
+				return !isAlnum(c)
+			}
+			return strings.ContainsRune(p.Symbols, c)
+		}},
+	}
+}
+
+// Enforce the policy on a generated password. Characters are only ever replaced, at positions
+// and with values chosen by a stream derived from the hash, so the same inputs always give the same result.
+// It works in characters rather than bytes, so non-ASCII symbols and passwords stay valid UTF-8.
+func applyPolicy(password string, policy Policy, hash []byte) (string, error) {
+	classes := policy.classes()
+	required := 0
+	for _, class := range classes {
+		required += class.min
+	}
+	length := utf8.RuneCountInString(password)
+	if required > length {
+		return "", &PolicyError{Policy: policy, Length: length}
+	}
+
+	stream := newDomainStream("policy", hash)
+	chars := []rune(password)
+
+	// Replace any symbols that aren't allowed
+	if policy.Symbols != "" {
+		symbols := []rune(policy.Symbols)
+		for i, c := range chars {
+			if !strings.ContainsRune(upperChars+lowerChars+digitChars+policy.Symbols, c) {
+				chars[i] = symbols[uniformIndex(stream, len(symbols))]
+			}
+		}
+	}
+
+	classOf := func(c rune) int {
+		for i, class := range classes {
+			if class.contains(c) {
+				return i
+			}
+		}
+		return -1
+	}
+	counts := make([]int, len(classes))
+	for _, c := range chars {
+		if i := classOf(c); i >= 0 {
+			counts[i]++
+		}
+	}
+
+	// Top up each short class by overwriting characters that no other class needs
+	locked := make([]bool, len(chars))
+	for i, class := range classes {
+		for counts[i] < class.min {
+			candidates := []int{}
+			for pos, c := range chars {
+				if locked[pos] {
+					continue
+				}
+				if j := classOf(c); j < 0 || counts[j] > classes[j].min {
+					candidates = append(candidates, pos)
+				}
+			}
+			if len(candidates) == 0 {
+				return "", &PolicyError{Policy: policy, Length: length}
+			}
+
+			pos := candidates[uniformIndex(stream, len(candidates))]
+			if j := classOf(chars[pos]); j >= 0 {
+				counts[j]--
+			}
+			chars[pos] = class.chars[uniformIndex(stream, len(class.chars))]
+			counts[i]++
+			locked[pos] = true
+		}
+	}
+
+	return string(chars), nil
+}
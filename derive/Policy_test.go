@@ -0,0 +1,47 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPolicyKeepsUTF8(t *testing.T) {
+	settings := []SavedSetting{
+		{CharRestrictions: RestrictNone, Length: 10, Policy: Policy{MinSymbol: 2, Symbols: "€£"}},
+		{CharRestrictions: RestrictCustom, Length: 10, Policy: Policy{MinDigit: 3},
+			Custom: CustomCharset{Name: "umlauts", Alphabet: "äöüABCabc123", Replacement: "ß"}},
+	}
+	for _, setting := range settings {
+		setting.Description = "example.com"
+		setting.Algorithm = AlgorithmSHA256
+		setting.Iterations = 1
+		password, err := Derive(setting, "master")
+		if err != nil {
+			t.Fatalf("%+v: %v", setting, err)
+		}
+		if !utf8.ValidString(password) {
+			t.Fatalf("%+v: %q isn't valid UTF-8", setting, password)
+		}
+		if length := utf8.RuneCountInString(password); length != setting.Length {
+			t.Errorf("%+v: %q is %d characters", setting, password, length)
+		}
+
+		counts := map[string]int{}
+		for _, c := range password {
+			switch {
+			case strings.ContainsRune(digitChars, c):
+				counts["digit"]++
+			case strings.ContainsRune(setting.Policy.Symbols, c):
+				counts["symbol"]++
+			}
+		}
+		if counts["digit"] < setting.Policy.MinDigit || counts["symbol"] < setting.Policy.MinSymbol {
+			t.Errorf("%+v: %q doesn't satisfy the policy", setting, password)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import "context"
+
+// Called periodically with the number of iterations done so far, out of the total
+type Progress func(done, total int)
+
+// How many iterations the loops run between checking for cancellation and reporting progress
+const progressInterval = 1024
+
+// Called by the iteration loops after each iteration
+func checkpoint(ctx context.Context, progress Progress, done, total int) error {
+	if done%progressInterval != 0 && done != total {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if progress != nil {
+		progress(done, total)
+	}
+	return nil
+}
+
+// Only one KDF that can't be interrupted runs at a time, counting any that have been abandoned.
+// Each can hold up to MaxMemory, so cancelling and retrying mustn't pile them up.
+var abandonableSlot = make(chan struct{}, 1)
+
+// Runs a KDF that can't be interrupted part way, returning early if ctx is done first.
+// The KDF still runs to completion in the background, but its result is discarded,
+// and the next one waits for it to finish.
+func runAbandonable(ctx context.Context, kdf func() ([]byte, error)) ([]byte, error) {
+	select {
+	case abandonableSlot <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	type result struct {
+		hash []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() { <-abandonableSlot }()
+		hash, err := kdf()
+		done <- result{hash, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.hash, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// An abandoned KDF holds on to its memory until it finishes, so the next one mustn't start until then
+func TestAbandonedKDFBlocksTheNext(t *testing.T) {
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	running := make(chan struct{})
+	abandoned := make(chan error, 1)
+	go func() {
+		_, err := runAbandonable(ctx, func() ([]byte, error) {
+			close(running)
+			<-release
+			return nil, nil
+		})
+		abandoned <- err
+	}()
+	<-running
+	cancel()
+	if err := <-abandoned; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+
+	started := make(chan struct{})
+	next := make(chan error, 1)
+	go func() {
+		_, err := runAbandonable(context.Background(), func() ([]byte, error) {
+			close(started)
+			return []byte{1}, nil
+		})
+		next <- err
+	}()
+	select {
+	case <-started:
+		t.Fatal("started while the abandoned KDF was still running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-next; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Waiting for an abandoned KDF can itself be cancelled
+func TestWaitingForAbandonedKDFCancels(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	ctx, cancel := context.WithCancel(context.Background())
+	running := make(chan struct{})
+	go runAbandonable(ctx, func() ([]byte, error) {
+		close(running)
+		<-release
+		return nil, nil
+	})
+	<-running
+	cancel()
+
+	waiting, cancelWaiting := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelWaiting()
+	_, err := runAbandonable(waiting, func() ([]byte, error) {
+		t.Error("started while the abandoned KDF was still running")
+		return nil, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
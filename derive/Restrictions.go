@@ -0,0 +1,99 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	"regexp"
+	"strings"
+)
+
+// The character restrictions, which match the Cryptnos character types.
+// Like the algorithms, these are stable identifiers and the UI has its own labels for them.
+const (
+	RestrictNone            = "none"
+	RestrictAlnumUnderscore = "alnum-underscore"
+	RestrictAlnumOmit       = "alnum-omit"
+	RestrictAlpha           = "alpha"
+	RestrictNumeric         = "numeric"
+)
+
+// Restrictions that map the raw hash output straight into an alphabet, rather than filtering encoded text.
+// These always give exactly Length characters, each equally likely.
+const (
+	RestrictUniformAlnum   = "exact-alnum"
+	RestrictUniformAlpha   = "exact-alpha"
+	RestrictUniformNumeric = "exact-numeric"
+)
+
+// A user defined restriction. The definition is in the setting's Custom field,
+// so each setting (and backup) is self-contained.
+const RestrictCustom = "custom"
+
+// A named set of allowed characters. Disallowed characters are replaced by
+// Replacement, or omitted if it's empty.
+type CustomCharset struct {
+	Name        string `json:"name"`
+	Alphabet    string `json:"alphabet"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+const (
+	upperChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	lowerChars = "abcdefghijklmnopqrstuvwxyz"
+	digitChars = "0123456789"
+)
+
+var uniformAlphabets = map[string]string{
+	RestrictUniformAlnum:   upperChars + lowerChars + digitChars,
+	RestrictUniformAlpha:   upperChars + lowerChars,
+	RestrictUniformNumeric: digitChars,
+}
+
+// The character restrictions, in the order they're offered in the UI
+var CharRestrictions = []string{
+	RestrictNone,
+	RestrictAlnumUnderscore,
+	RestrictAlnumOmit,
+	RestrictAlpha,
+	RestrictNumeric,
+	RestrictUniformAlnum,
+	RestrictUniformAlpha,
+	RestrictUniformNumeric,
+	RestrictPassphrase,
+}
+
+func applyCustomCharset(hash string, custom CustomCharset) string {
+	var result strings.Builder
+	for _, c := range hash {
+		if strings.ContainsRune(custom.Alphabet, c) {
+			result.WriteRune(c)
+		} else {
+			result.WriteString(custom.Replacement)
+		}
+	}
+	return result.String()
+}
+
+func applyCharacterRestrictions(hash, restriction string) string {
+	switch restriction {
+	case RestrictNone:
+		return hash
+	case RestrictAlnumUnderscore:
+		re := regexp.MustCompile(`[^a-zA-Z0-9]`)
+		return re.ReplaceAllString(hash, "_")
+	case RestrictAlnumOmit:
+		re := regexp.MustCompile(`[^a-zA-Z0-9]`)
+		return re.ReplaceAllString(hash, "")
+	case RestrictAlpha:
+		re := regexp.MustCompile(`[^a-zA-Z]`)
+		return re.ReplaceAllString(hash, "")
+	case RestrictNumeric:
+		re := regexp.MustCompile(`[^0-9]`)
+		result := re.ReplaceAllString(hash, "")
+		return result
+	default:
+		return hash
+	}
+}
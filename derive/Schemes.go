@@ -0,0 +1,136 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/binary"
+	"strconv"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// How the description and master password are fed to the hash algorithm.
+// An empty Scheme is the same as SchemeConcatenate, so settings saved before schemes existed are unchanged.
+// These are stable identifiers, the UI has its own labels for them.
+const (
+	SchemeConcatenate = "concatenate" // hash(description + master), as Cryptnos does it
+	SchemeHMAC        = "hmac"        // HMAC(key=master, msg=description)
+	SchemePBKDF2      = "pbkdf2"      // PBKDF2-HMAC(password=master, salt=description, cost=iterations)
+	SchemeArgon2id    = "argon2id"    // Argon2id(password=master, salt=description, time=iterations, memory, parallelism)
+	SchemeScrypt      = "scrypt"      // scrypt(password=master, salt=description, N=memory, r=8, p=parallelism)
+)
+
+// The most memory (KiB) the memory-hard KDFs may use. Much more than this is almost certainly a typo,
+// and trying to allocate it kills the process outright rather than failing with an error.
+const MaxMemory = 4 * 1024 * 1024 // 4 GiB
+
+// Argon2id keeps its parallelism in a byte
+const MaxParallelism = 255
+
+// Output size of the memory-hard KDFs, which don't use the selected digest algorithm
+const kdfKeyLength = 32
+
+// scrypt block size. At r=8 each unit of N costs exactly 1 KiB, so Memory means the same for both KDFs
+const scryptBlockSize = 8
+
+// The derivation schemes, in the order they're offered in the UI
+var Schemes = []string{
+	SchemeConcatenate,
+	SchemeHMAC,
+	SchemePBKDF2,
+	SchemeArgon2id,
+	SchemeScrypt,
+}
+
+// Reports whether a scheme uses the Memory and Parallelism fields of a setting
+func UsesMemoryParams(scheme string) bool {
+	return scheme == SchemeArgon2id || scheme == SchemeScrypt
+}
+
+// The first iteration is HMAC(master, description), then each further iteration
+// is keyed by the master again over the previous result
+func getHMACWithIterations(ctx context.Context, key, message []byte, algorithm string, iterCount int, progress Progress) ([]byte, error) {
+	if _, isXOF := xofSizes[algorithm]; isXOF {
+		return nil, &IncompatibleAlgorithmError{Scheme: SchemeHMAC, Algorithm: algorithm}
+	}
+	newHash, err := hashConstructor(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	result := message
+	for i := 0; i < iterCount; i++ {
+		mac := hmac.New(newHash, key)
+		_, err = mac.Write(result)
+		if err != nil {
+			return nil, &IterationError{Iteration: i + 1, Err: err}
+		}
+		result = mac.Sum(nil)
+		if err = checkpoint(ctx, progress, i+1, iterCount); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// Standard PBKDF2 with the chosen digest as the HMAC hash, producing one digest's worth of output.
+// That's a single PBKDF2 block, computed here rather than by crypto/pbkdf2 so it can be cancelled.
+func getPBKDF2(ctx context.Context, password string, salt []byte, algorithm string, iterCount int, progress Progress) ([]byte, error) {
+	if _, isXOF := xofSizes[algorithm]; isXOF {
+		return nil, &IncompatibleAlgorithmError{Scheme: SchemePBKDF2, Algorithm: algorithm}
+	}
+	newHash, err := hashConstructor(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	prf := hmac.New(newHash, []byte(password))
+	prf.Write(salt)
+	prf.Write(binary.BigEndian.AppendUint32(nil, 1))
+	u := prf.Sum(nil)
+	key := append([]byte{}, u...)
+	for i := 2; i <= iterCount; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+		if err = checkpoint(ctx, progress, i, iterCount); err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// Checks the memory (KiB) and parallelism fields used by the memory-hard KDFs
+func getMemoryParams(setting SavedSetting) (memory, parallelism int, err error) {
+	memory, parallelism = setting.Memory, setting.Parallelism
+	if memory < 1 || memory > MaxMemory {
+		return 0, 0, &InvalidParameterError{Parameter: "memory", Value: strconv.Itoa(memory)}
+	}
+	if parallelism < 1 || parallelism > MaxParallelism {
+		return 0, 0, &InvalidParameterError{Parameter: "parallelism", Value: strconv.Itoa(parallelism)}
+	}
+	return memory, parallelism, nil
+}
+
+// Argon2id with Iterations as the time cost
+func getArgon2id(password string, salt []byte, iterCount, memory, parallelism int) []byte {
+	return argon2.IDKey([]byte(password), salt, uint32(iterCount), uint32(memory), uint8(parallelism), kdfKeyLength)
+}
+
+// scrypt has no separate time cost, so Iterations only needs to be positive (0 still marks an inactive setting)
+func getScrypt(password string, salt []byte, memory, parallelism int) ([]byte, error) {
+	if memory < 2 || memory&(memory-1) != 0 {
+		return nil, &InvalidParameterError{Parameter: "memory (must be a power of 2 for scrypt)", Value: strconv.Itoa(memory)}
+	}
+	return scrypt.Key([]byte(password), salt, memory, scryptBlockSize, parallelism, kdfKeyLength)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	"bytes"
+	"context"
+	"crypto/pbkdf2"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// Out of range memory-hard parameters must be rejected before anything is allocated
+func TestMemoryParamLimits(t *testing.T) {
+	for _, scheme := range []string{SchemeArgon2id, SchemeScrypt} {
+		for _, params := range [][2]int{{0, 1}, {MaxMemory + 1, 1}, {100000000, 1}, {1024, 0}, {1024, MaxParallelism + 1}} {
+			setting := SavedSetting{Description: "d", Algorithm: AlgorithmSHA256, CharRestrictions: RestrictNone,
+				Iterations: 1, Scheme: scheme, Memory: params[0], Parallelism: params[1]}
+			_, err := Derive(setting, "master")
+			var invalid *InvalidParameterError
+			if !errors.As(err, &invalid) {
+				t.Errorf("%s memory %d parallelism %d: got %v, want an InvalidParameterError", scheme, params[0], params[1], err)
+			}
+		}
+	}
+}
+
+// The RFC 6070 PBKDF2-HMAC-SHA1 vectors that fit in one block ("password", "salt", 20 bytes)
+func TestPBKDF2RFC6070(t *testing.T) {
+	for _, v := range []struct {
+		iterations int
+		key        string
+	}{
+		{1, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{2, "ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957"},
+		{4096, "4b007901b765489abead49d926f721d065a429c1"},
+	} {
+		key, err := getPBKDF2(context.Background(), "password", []byte("salt"), AlgorithmSHA1, v.iterations, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(key); got != v.key {
+			t.Errorf("%d iterations: got %s, want %s", v.iterations, got, v.key)
+		}
+	}
+}
+
+// The hand-written block loop matches the standard library for every digest
+func TestPBKDF2MatchesStandardLibrary(t *testing.T) {
+	for _, algorithm := range Algorithms {
+		if _, isXOF := xofSizes[algorithm]; isXOF {
+			continue
+		}
+		newHash, err := hashConstructor(algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, iterations := range []int{1, 2, 3, 1000, 1025} {
+			got, err := getPBKDF2(context.Background(), "master", []byte("example.com"), algorithm, iterations, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := pbkdf2.Key(newHash, "master", []byte("example.com"), iterations, newHash().Size())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s, %d iterations: got %x, want %x", algorithm, iterations, got, want)
+			}
+		}
+	}
+}
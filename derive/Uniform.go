@@ -0,0 +1,61 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	"bytes"
+	"crypto/sha3"
+	"io"
+)
+
+// An endless deterministic byte stream: the hash output itself, then as much SHAKE256 of it as is needed
+func newByteStream(hash []byte) io.Reader {
+	xof := sha3.NewSHAKE256()
+	xof.Write(hash)
+	return io.MultiReader(bytes.NewReader(hash), xof)
+}
+
+// A stream of SHAKE256 over a domain label and the hash output, which keeps
+// each use of the stream independent of any other use of the same hash output
+func newDomainStream(domain string, hash []byte) io.Reader {
+	xof := sha3.NewSHAKE256()
+	xof.Write([]byte(domain))
+	xof.Write(hash)
+	return xof
+}
+
+// Returns an index in [0,n) with every value equally likely, by rejecting
+// stream values from the incomplete range at the top rather than wrapping them
+func uniformIndex(stream io.Reader, n int) int {
+	// Use as few bytes per sample as will cover n
+	size := 1
+	for limit := 256; limit < n; limit *= 256 {
+		size++
+	}
+	span := uint64(1) << (8 * size)
+	limit := span - span%uint64(n)
+
+	sample := make([]byte, size)
+	for {
+		io.ReadFull(stream, sample)
+		value := uint64(0)
+		for _, b := range sample {
+			value = value<<8 | uint64(b)
+		}
+		if value < limit {
+			return int(value % uint64(n))
+		}
+	}
+}
+
+// Map the hash output into exactly length characters of the alphabet
+func mapToAlphabet(hash []byte, alphabet string, length int) string {
+	stream := newByteStream(hash)
+	output := make([]byte, length)
+	for i := range output {
+		output[i] = alphabet[uniformIndex(stream, len(alphabet))]
+	}
+	return string(output)
+}
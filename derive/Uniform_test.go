@@ -0,0 +1,78 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Computed with a separate implementation of the mapping: the digest, then SHAKE256 of it,
+// read a byte at a time with values from the incomplete range at the top rejected
+func TestDeriveExactLength(t *testing.T) {
+	testDerive(t, []deriveVector{
+		{SavedSetting{Description: "example.com", Algorithm: AlgorithmSHA256, CharRestrictions: RestrictUniformAlnum, Length: 20, Iterations: 1}, "master",
+			"fEDoTLfH4SN61h0C2JYI"},
+		{SavedSetting{Description: "example.com", Algorithm: AlgorithmSHA512, CharRestrictions: RestrictUniformAlpha, Length: 12, Iterations: 3}, "master",
+			"ujJYohedpmSA"},
+		{SavedSetting{Description: "bank", Algorithm: AlgorithmSHA256, CharRestrictions: RestrictUniformNumeric, Length: 8, Iterations: 1}, "master",
+			"03301976"},
+		// Longer than the digest, so it carries on into the SHAKE256 stream
+		{SavedSetting{Description: "bank", Algorithm: AlgorithmSHA256, CharRestrictions: RestrictUniformNumeric, Length: 100, Iterations: 1}, "master",
+			"0330197608117935501382181525240261550208499643320201292762608275974013967081513019053751713141603786"},
+	})
+}
+
+// Every exact-length restriction gives exactly Length characters from its alphabet, the same every time
+func TestExactLengthRestrictions(t *testing.T) {
+	for restriction, alphabet := range uniformAlphabets {
+		for _, scheme := range []string{SchemeConcatenate, SchemeHMAC, SchemePBKDF2} {
+			for _, algorithm := range []string{AlgorithmMD5, AlgorithmSHA256, AlgorithmSHAKE256} {
+				if scheme != SchemeConcatenate && algorithm == AlgorithmSHAKE256 {
+					continue // the keyed schemes need a fixed-size digest
+				}
+				for _, length := range []int{1, 12, 64, 300} {
+					setting := SavedSetting{Description: "example.com", Algorithm: algorithm, Scheme: scheme,
+						CharRestrictions: restriction, Length: length, Iterations: 2}
+					password, err := Derive(setting, "master")
+					if err != nil {
+						t.Fatalf("%+v: %v", setting, err)
+					}
+					if len(password) != length {
+						t.Errorf("%+v: got %d characters, want %d", setting, len(password), length)
+					}
+					if i := strings.IndexFunc(password, func(c rune) bool { return !strings.ContainsRune(alphabet, c) }); i >= 0 {
+						t.Errorf("%+v: %q isn't in the alphabet", setting, password[i])
+					}
+					again, err := Derive(setting, "master")
+					if err != nil || again != password {
+						t.Errorf("%+v: got %q then %q", setting, password, again)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Ranges over 256 take more than one byte per sample, read big-endian, with the incomplete range at the top rejected
+func TestUniformIndex(t *testing.T) {
+	for _, test := range []struct {
+		n      int
+		stream []byte
+		want   int
+	}{
+		{10, []byte{255, 250, 123}, 3},            // 250 and up are rejected
+		{256, []byte{255}, 255},                   // nothing to reject
+		{257, []byte{1, 1}, 0},                    // two bytes
+		{1000, []byte{0xff, 0xff, 1, 2}, 258},     // 65000 and up are rejected
+		{7776, []byte{0xfd, 0x20, 0x1e, 0x61}, 1}, // the wordlist: 62208 and up are rejected
+		{65537, []byte{0, 1, 0}, 256},             // three bytes
+	} {
+		if got := uniformIndex(bytes.NewReader(test.stream), test.n); got != test.want {
+			t.Errorf("uniformIndex(%v, %d) = %d, want %d", test.stream, test.n, got, test.want)
+		}
+	}
+}
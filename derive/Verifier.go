@@ -0,0 +1,47 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package derive
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// The verifier tag is deliberately short: long enough to catch a typo almost every time,
+// but matched by so many other passwords that a stolen verifier can't confirm a guess at the master
+const (
+	verifierTime        = 2
+	verifierMemory      = 32 * 1024 // KiB
+	verifierParallelism = 1
+	verifierSaltLength  = 16
+	verifierTagLength   = 2
+)
+
+// A salted, slow, short tag of a master password, for checking it's been typed correctly
+type Verifier struct {
+	Name string `json:"name"`
+	Salt []byte `json:"salt"`
+	Tag  []byte `json:"tag"`
+}
+
+// NewVerifier makes a verifier for the master password with a random salt
+func NewVerifier(name, master string) (Verifier, error) {
+	salt := make([]byte, verifierSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return Verifier{}, err
+	}
+	return Verifier{Name: name, Salt: salt, Tag: verifierTag(master, salt)}, nil
+}
+
+// Matches reports whether the master password (probably) is the one the verifier was made from
+func (v Verifier) Matches(master string) bool {
+	return subtle.ConstantTimeCompare(verifierTag(master, v.Salt), v.Tag) == 1
+}
+
+func verifierTag(master string, salt []byte) []byte {
+	return argon2.IDKey([]byte(master), salt, verifierTime, verifierMemory, verifierParallelism, verifierTagLength)
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package schema
+
+import (
+	"strconv"
+	"strings"
+
+	"HashMaster3000/derive"
+)
+
+// Each migration upgrades one raw setting (and its history) from the version it's indexed by to the next
+var migrations = map[int]func(setting map[string]any){
+	1: numbersFromStrings,
+	2: idsFromLabels,
+}
+
+// Version 1 stored the numeric fields as the text of the form's entries
+var v1NumericFields = []string{"length", "iterations", "memory", "parallelism"}
+
+// Converts the numeric fields to numbers. Empty fields become 0 (no length restriction, or unused).
+// A field that isn't a number never generated a password, so the setting is kept but marked
+// inactive (0 iterations) rather than given a made-up value.
+func numbersFromStrings(setting map[string]any) {
+	invalid := false
+	for _, field := range v1NumericFields {
+		text, isString := setting[field].(string)
+		if !isString {
+			continue
+		}
+		delete(setting, field)
+		if text == "" {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil {
+			invalid = true
+			continue
+		}
+		setting[field] = number
+	}
+	if invalid {
+		setting["iterations"] = 0
+	}
+
+	upgradeHistory(setting, numbersFromStrings)
+}
+
+// Versions before 3 stored the algorithms, restrictions, schemes and encodings by their UI labels
+var v2AlgorithmLabels = map[string]string{
+	"SHA-256":    derive.AlgorithmSHA256,
+	"SHA-512":    derive.AlgorithmSHA512,
+	"SHA-1":      derive.AlgorithmSHA1,
+	"MD5":        derive.AlgorithmMD5,
+	"SHA-224":    derive.AlgorithmSHA224,
+	"SHA-384":    derive.AlgorithmSHA384,
+	"SHA3-224":   derive.AlgorithmSHA3_224,
+	"SHA3-256":   derive.AlgorithmSHA3_256,
+	"SHA3-384":   derive.AlgorithmSHA3_384,
+	"SHA3-512":   derive.AlgorithmSHA3_512,
+	"SHAKE128":   derive.AlgorithmSHAKE128,
+	"SHAKE256":   derive.AlgorithmSHAKE256,
+	"RIPEMD-160": derive.AlgorithmRIPEMD160,
+	"Whirlpool":  derive.AlgorithmWhirlpool,
+	"Tiger":      derive.AlgorithmTiger,
+}
+
+var v2RestrictionLabels = map[string]string{
+	"All generated chars":                           derive.RestrictNone,
+	"Alphanumeric (replace others with underscore)": derive.RestrictAlnumUnderscore,
+	"Alphanumeric (omit others)":                    derive.RestrictAlnumOmit,
+	"Alpha only":                                    derive.RestrictAlpha,
+	"Numeric only":                                  derive.RestrictNumeric,
+	"Alphanumeric (exact length)":                   derive.RestrictUniformAlnum,
+	"Alpha only (exact length)":                     derive.RestrictUniformAlpha,
+	"Numeric only (exact length)":                   derive.RestrictUniformNumeric,
+	"Custom":                                        derive.RestrictCustom,
+	"Passphrase (words)":                            derive.RestrictPassphrase,
+}
+
+// AlgorithmID converts an algorithm label from before version 3 to its identifier.
+// Anything else (including identifiers) is returned unchanged.
+func AlgorithmID(label string) string {
+	if id, isLabel := v2AlgorithmLabels[label]; isLabel {
+		return id
+	}
+	return label
+}
+
+// RestrictionID converts a restriction label from before version 3 to its identifier.
+// Anything else (including identifiers) is returned unchanged.
+func RestrictionID(label string) string {
+	if id, isLabel := v2RestrictionLabels[label]; isLabel {
+		return id
+	}
+	return label
+}
+
+var v2SchemeLabels = map[string]string{
+	"Concatenate": derive.SchemeConcatenate,
+	"HMAC":        derive.SchemeHMAC,
+	"PBKDF2":      derive.SchemePBKDF2,
+	"Argon2id":    derive.SchemeArgon2id,
+	"scrypt":      derive.SchemeScrypt,
+}
+
+var v2EncodingLabels = map[string]string{
+	"Base64":          derive.EncodingBase64,
+	"Base64 URL-safe": derive.EncodingBase64URL,
+	"Hex":             derive.EncodingHex,
+	"Base32":          derive.EncodingBase32,
+	"Base58":          derive.EncodingBase58,
+	"Z85":             derive.EncodingZ85,
+}
+
+// SchemeID converts a scheme label from before version 3 to its identifier.
+// Anything else (including identifiers) is returned unchanged.
+func SchemeID(label string) string {
+	if id, isLabel := v2SchemeLabels[label]; isLabel {
+		return id
+	}
+	return label
+}
+
+// EncodingID converts an encoding label from before version 3 to its identifier.
+// Anything else (including identifiers) is returned unchanged.
+func EncodingID(label string) string {
+	if id, isLabel := v2EncodingLabels[label]; isLabel {
+		return id
+	}
+	return label
+}
+
+func idsFromLabels(setting map[string]any) {
+	if label, isString := setting["algorithm"].(string); isString {
+		setting["algorithm"] = AlgorithmID(label)
+	}
+	if label, isString := setting["char_restrictions"].(string); isString {
+		setting["char_restrictions"] = RestrictionID(label)
+	}
+	if label, isString := setting["scheme"].(string); isString {
+		setting["scheme"] = SchemeID(label)
+	}
+	if label, isString := setting["encoding"].(string); isString {
+		setting["encoding"] = EncodingID(label)
+	}
+	upgradeHistory(setting, idsFromLabels)
+}
+
+// The parameters before each rotation are settings too
+func upgradeHistory(setting map[string]any, migration func(map[string]any)) {
+	history, _ := setting["history"].([]any)
+	for _, previous := range history {
+		if object, isObject := previous.(map[string]any); isObject {
+			migration(object)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+// Package schema reads and writes HM3k's saved settings documents (preferences, vaults and backups).
+//
+// A document records the schema version it was written with. Reading upgrades older documents,
+// including the unversioned map of settings written before there was a schema, one version at a time.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"HashMaster3000/derive"
+)
+
+// The version written by Marshal
+const Version = 3
+
+// The unversioned map of settings written before there was a schema
+const legacyVersion = 1
+
+type document struct {
+	SchemaVersion int                            `json:"schema_version"`
+	Settings      map[string]derive.SavedSetting `json:"settings"`
+}
+
+// Marshal encodes the settings as a document of the current version
+func Marshal(settings map[string]derive.SavedSetting) ([]byte, error) {
+	return json.Marshal(document{SchemaVersion: Version, Settings: settings})
+}
+
+// MarshalIndent is Marshal with indentation, for backups people might read
+func MarshalIndent(settings map[string]derive.SavedSetting, prefix, indent string) ([]byte, error) {
+	return json.MarshalIndent(document{SchemaVersion: Version, Settings: settings}, prefix, indent)
+}
+
+// Unmarshal decodes a document of any version up to the current one, upgrading it as it goes
+func Unmarshal(data []byte) (map[string]derive.SavedSetting, error) {
+	// Migrations work on the raw JSON, since older versions don't fit the current types.
+	// Numbers are kept as written, so they're exact whatever their size.
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var raw map[string]any
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	version := legacyVersion
+	if number, versioned := raw["schema_version"].(json.Number); versioned {
+		v, err := number.Int64()
+		if err != nil || v < legacyVersion {
+			return nil, fmt.Errorf("invalid schema version: %s", number)
+		}
+		if v > Version {
+			return nil, fmt.Errorf("schema version %d is newer than this version of HM3k understands (%d)", v, Version)
+		}
+		version = int(v)
+	} else {
+		raw = map[string]any{"schema_version": legacyVersion, "settings": raw}
+	}
+
+	for ; version < Version; version++ {
+		settings, _ := raw["settings"].(map[string]any)
+		for description, setting := range settings {
+			object, isObject := setting.(map[string]any)
+			if !isObject {
+				return nil, fmt.Errorf("setting '%s' isn't an object", description)
+			}
+			migrations[version](object)
+		}
+		raw["schema_version"] = version + 1
+	}
+
+	upgraded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var doc document
+	if err = json.Unmarshal(upgraded, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Settings == nil {
+		doc.Settings = make(map[string]derive.SavedSetting)
+	}
+	for description, setting := range doc.Settings {
+		doc.Settings[description] = checkNumbers(setting)
+	}
+	return doc.Settings, nil
+}
+
+// A setting with out of range numbers never generated a password. Like one whose numbers weren't numbers,
+// it's kept but marked inactive (0 iterations), with the bad fields cleared rather than given made-up values.
+func checkNumbers(setting derive.SavedSetting) derive.SavedSetting {
+	invalid := setting.Iterations < 0
+	if setting.Length < 0 {
+		setting.Length = 0
+		invalid = true
+	}
+	if setting.Counter < 0 {
+		setting.Counter = 0
+		invalid = true
+	}
+	if derive.UsesMemoryParams(setting.Scheme) {
+		if setting.Memory < 1 || setting.Memory > derive.MaxMemory {
+			setting.Memory = 0
+			invalid = true
+		}
+		if setting.Parallelism < 1 || setting.Parallelism > derive.MaxParallelism {
+			setting.Parallelism = 0
+			invalid = true
+		}
+	}
+	if invalid {
+		setting.Iterations = 0
+	}
+
+	for i, previous := range setting.History {
+		setting.History[i] = checkNumbers(previous)
+	}
+	return setting
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package schema
+
+import (
+	"reflect"
+	"testing"
+
+	"HashMaster3000/derive"
+)
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want map[string]derive.SavedSetting
+	}{
+		{
+			name: "v1 to current",
+			data: `{"example.com":{"description":"example.com","algorithm":"SHA-256",
+				"char_restrictions":"Alphanumeric (replace others with underscore)","length":"12","iterations":"2"}}`,
+			want: map[string]derive.SavedSetting{
+				"example.com": {Description: "example.com", Algorithm: derive.AlgorithmSHA256,
+					CharRestrictions: derive.RestrictAlnumUnderscore, Length: 12, Iterations: 2},
+			},
+		},
+		{
+			name: "v1 empty length is no restriction",
+			data: `{"a":{"description":"a","algorithm":"MD5","char_restrictions":"Numeric only","length":"","iterations":" 5 "}}`,
+			want: map[string]derive.SavedSetting{
+				"a": {Description: "a", Algorithm: derive.AlgorithmMD5, CharRestrictions: derive.RestrictNumeric, Iterations: 5},
+			},
+		},
+		{
+			name: "v1 non-numeric strings mark the setting inactive",
+			data: `{"a":{"description":"a","algorithm":"SHA-1","char_restrictions":"Alpha only","length":"twelve","iterations":"3"},
+				"b":{"description":"b","algorithm":"SHA-1","char_restrictions":"Alpha only","length":"8","iterations":"1e3"}}`,
+			want: map[string]derive.SavedSetting{
+				"a": {Description: "a", Algorithm: derive.AlgorithmSHA1, CharRestrictions: derive.RestrictAlpha, Iterations: 0},
+				"b": {Description: "b", Algorithm: derive.AlgorithmSHA1, CharRestrictions: derive.RestrictAlpha, Length: 8, Iterations: 0},
+			},
+		},
+		{
+			name: "v2 to current",
+			data: `{"schema_version":2,"settings":{"a":{"description":"a","algorithm":"Tiger",
+				"char_restrictions":"Alphanumeric (exact length)","length":10,"iterations":4}}}`,
+			want: map[string]derive.SavedSetting{
+				"a": {Description: "a", Algorithm: derive.AlgorithmTiger, CharRestrictions: derive.RestrictUniformAlnum, Length: 10, Iterations: 4},
+			},
+		},
+		{
+			name: "v1 history is upgraded too",
+			data: `{"a":{"description":"a","algorithm":"SHA-512","char_restrictions":"All generated chars","length":"16","iterations":"2",
+				"counter":1,"history":[{"description":"a","algorithm":"SHA-1","char_restrictions":"Alpha only","length":"8","iterations":"x"}]}}`,
+			want: map[string]derive.SavedSetting{
+				"a": {Description: "a", Algorithm: derive.AlgorithmSHA512, CharRestrictions: derive.RestrictNone, Length: 16, Iterations: 2,
+					Counter: 1, History: []derive.SavedSetting{
+						{Description: "a", Algorithm: derive.AlgorithmSHA1, CharRestrictions: derive.RestrictAlpha, Length: 8, Iterations: 0},
+					}},
+			},
+		},
+		{
+			name: "out of range numbers mark the setting inactive",
+			data: `{"a":{"description":"a","algorithm":"SHA-256","char_restrictions":"All generated chars","length":"-5","iterations":"-3"},
+				"b":{"description":"b","algorithm":"SHA-256","char_restrictions":"All generated chars","length":"12","iterations":"2","counter":-1}}`,
+			want: map[string]derive.SavedSetting{
+				"a": {Description: "a", Algorithm: derive.AlgorithmSHA256, CharRestrictions: derive.RestrictNone, Iterations: 0},
+				"b": {Description: "b", Algorithm: derive.AlgorithmSHA256, CharRestrictions: derive.RestrictNone, Length: 12, Iterations: 0},
+			},
+		},
+		{
+			name: "memory-hard parameters out of range mark the setting inactive",
+			data: `{"schema_version":3,"settings":{"a":{"description":"a","algorithm":"sha256","char_restrictions":"none","length":12,"iterations":3,
+				"scheme":"argon2id","memory":100000000,"parallelism":1},
+				"b":{"description":"b","algorithm":"sha256","char_restrictions":"none","length":12,"iterations":3,
+				"scheme":"scrypt","memory":1024,"parallelism":256}}}`,
+			want: map[string]derive.SavedSetting{
+				"a": {Description: "a", Algorithm: derive.AlgorithmSHA256, CharRestrictions: derive.RestrictNone, Length: 12, Iterations: 0,
+					Scheme: derive.SchemeArgon2id, Parallelism: 1},
+				"b": {Description: "b", Algorithm: derive.AlgorithmSHA256, CharRestrictions: derive.RestrictNone, Length: 12, Iterations: 0,
+					Scheme: derive.SchemeScrypt, Memory: 1024},
+			},
+		},
+		{
+			name: "v2 schemes and encodings to identifiers",
+			data: `{"schema_version":2,"settings":{"a":{"description":"a","algorithm":"SHA-512","char_restrictions":"All generated chars","length":0,"iterations":1000,
+				"scheme":"PBKDF2","encoding":"Base64 URL-safe","counter":1,
+				"history":[{"description":"a","algorithm":"SHA-512","char_restrictions":"All generated chars","length":0,"iterations":1,"scheme":"HMAC","encoding":"Z85"}]}}}`,
+			want: map[string]derive.SavedSetting{
+				"a": {Description: "a", Algorithm: derive.AlgorithmSHA512, CharRestrictions: derive.RestrictNone, Iterations: 1000,
+					Scheme: derive.SchemePBKDF2, Encoding: derive.EncodingBase64URL, Counter: 1, History: []derive.SavedSetting{
+						{Description: "a", Algorithm: derive.AlgorithmSHA512, CharRestrictions: derive.RestrictNone, Iterations: 1,
+							Scheme: derive.SchemeHMAC, Encoding: derive.EncodingZ85},
+					}},
+			},
+		},
+		{
+			name: "empty current document",
+			data: `{"schema_version":3,"settings":{}}`,
+			want: map[string]derive.SavedSetting{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Unmarshal([]byte(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v\nwant %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalRejects(t *testing.T) {
+	for name, data := range map[string]string{
+		"newer version":      `{"schema_version":99,"settings":{}}`,
+		"invalid version":    `{"schema_version":0,"settings":{}}`,
+		"fractional version": `{"schema_version":2.5,"settings":{}}`,
+		"setting not object": `{"a":"SHA-256"}`,
+		"not JSON":           `settings`,
+	} {
+		if _, err := Unmarshal([]byte(data)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+// Round trips through the current version unchanged
+func TestMarshalRoundTrip(t *testing.T) {
+	settings := map[string]derive.SavedSetting{
+		"a": {Description: "a", Algorithm: derive.AlgorithmSHA3_256, CharRestrictions: derive.RestrictCustom, Length: 20, Iterations: 7,
+			Custom: derive.CustomCharset{Name: "hex", Alphabet: "0123456789abcdef"}, Counter: 2, Identity: "work"},
+	}
+	data, err := Marshal(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, settings) {
+		t.Errorf("got %+v\nwant %+v", got, settings)
+	}
+}
+
+// Settings saved before the schema still generate the same password
+func TestLegacyPasswordUnchanged(t *testing.T) {
+	settings, err := Unmarshal([]byte(`{"example.com":{"description":"example.com","algorithm":"SHA-256",
+		"char_restrictions":"Alphanumeric (replace others with underscore)","length":"12","iterations":"2"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, err := derive.Derive(settings["example.com"], "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password != "S_t8BzM9Hv_p" {
+		t.Errorf("got %q, want %q", password, "S_t8BzM9Hv_p")
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// How long after the last change a file store is written. The app saves its preferences as the form is typed in,
+// so writing (and syncing) the whole file on every change would stall typing and wear out removable drives.
+const writeDelay = time.Second
+
+// The documents of a file store, held in memory and written back to the file in the background
+type documents struct {
+	path   string
+	encode func(documents map[string]json.RawMessage) ([]byte, error) // the file's contents
+	delay  time.Duration
+
+	mu      sync.Mutex // guards everything below
+	docs    map[string]json.RawMessage
+	dirty   bool // changed since the file was last written
+	timer   *time.Timer
+	failed  bool // the last write failed, so the next failure isn't reported again
+	onError func(error)
+
+	writing sync.Mutex // so writes land in the order they were encoded
+}
+
+func newDocuments(path string, encode func(map[string]json.RawMessage) ([]byte, error)) documents {
+	return documents{path: path, encode: encode, delay: writeDelay, docs: make(map[string]json.RawMessage)}
+}
+
+func (d *documents) Get(name string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return string(d.docs[name])
+}
+
+func (d *documents) Set(name, value string) error {
+	if !json.Valid([]byte(value)) {
+		return fmt.Errorf("document %s isn't JSON", name)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.docs[name] = json.RawMessage(value)
+	d.changed()
+	return nil
+}
+
+func (d *documents) Remove(name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.docs, name)
+	d.changed()
+	return nil
+}
+
+// SetOnError sets what's called, from the background, when writing the file fails.
+// It's called once when writes start failing, and again only after one has succeeded.
+func (d *documents) SetOnError(onError func(error)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.onError = onError
+}
+
+// Put off writing until there's a pause in the changes. Called with mu held.
+func (d *documents) changed() {
+	d.dirty = true
+	if d.timer == nil {
+		d.timer = time.AfterFunc(d.delay, d.writeInBackground)
+	} else {
+		d.timer.Reset(d.delay)
+	}
+}
+
+func (d *documents) writeInBackground() {
+	err := d.Flush()
+	d.mu.Lock()
+	report := err != nil && !d.failed && d.onError != nil
+	d.failed = err != nil
+	onError := d.onError
+	d.mu.Unlock()
+	if report {
+		onError(err)
+	}
+}
+
+// Flush writes any changes that haven't been yet, and waits for them to be written
+func (d *documents) Flush() error {
+	d.writing.Lock()
+	defer d.writing.Unlock()
+
+	d.mu.Lock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	if !d.dirty {
+		d.mu.Unlock()
+		return nil
+	}
+	data, err := d.encode(d.docs)
+	d.dirty = false
+	d.mu.Unlock()
+
+	if err == nil {
+		err = writeFileAtomic(d.path, data)
+	}
+	if err != nil {
+		// Try again with the next change, or the next flush
+		d.mu.Lock()
+		d.dirty = true
+		d.mu.Unlock()
+	}
+	return err
+}
+
+// Write to a temporary file then rename it over the original, so an interrupted write can't leave half a file
+func writeFileAtomic(path string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name()) // fails harmlessly once it's been renamed
+
+	if _, err = temp.Write(data); err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"HashMaster3000/vault"
+)
+
+// Keeps every document in one file, sealed as a whole in a vault, so even the app preferences are unreadable without the passphrase
+type EncryptedFileStore struct {
+	documents
+	vault *vault.Vault
+}
+
+// OpenEncryptedFile unlocks the store at path with the passphrase, or starts an empty one
+// (locked with the passphrase) if the file doesn't exist yet. A wrong passphrase gives vault.ErrDecrypt.
+func OpenEncryptedFile(path, passphrase string) (*EncryptedFileStore, error) {
+	s := &EncryptedFileStore{}
+	s.documents = newDocuments(path, func(documents map[string]json.RawMessage) ([]byte, error) {
+		payload, err := json.Marshal(documents)
+		if err != nil {
+			return nil, err
+		}
+		return s.vault.Seal(payload)
+	})
+	sealed, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.vault, err = vault.Create(passphrase)
+		return s, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var payload []byte
+	s.vault, payload, err = vault.Unlock(sealed, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(payload, &s.docs); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"HashMaster3000/vault"
+)
+
+func TestEncryptedFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "settings.hm3k")
+
+	// A missing file is a new store, locked with the passphrase it was opened with
+	s, err := OpenEncryptedFile(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Get("savedSettings"); got != "" {
+		t.Errorf("got %q from an empty store", got)
+	}
+	if err = s.Set("savedSettings", `{"schema_version":3,"settings":{"bank":{}}}`); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	checkNoTempFiles(t, dir)
+
+	sealed, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("bank")) {
+		t.Error("the file isn't encrypted")
+	}
+
+	reopened, err := OpenEncryptedFile(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Get("savedSettings"); !jsonEqual(t, got, `{"schema_version":3,"settings":{"bank":{}}}`) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestEncryptedFileWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.hm3k")
+	s, err := OpenEncryptedFile(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Set("appPreferences", `{}`); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenEncryptedFile(path, "battery staple"); !errors.Is(err, vault.ErrDecrypt) {
+		t.Errorf("got %v, want %v", err, vault.ErrDecrypt)
+	}
+}
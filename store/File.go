@@ -0,0 +1,36 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Keeps every document in one readable JSON file, e.g. on a synced or removable drive
+type FileStore struct {
+	documents
+}
+
+// OpenFile reads the store at path, or starts an empty one if the file doesn't exist yet
+func OpenFile(path string) (*FileStore, error) {
+	s := &FileStore{newDocuments(path, func(documents map[string]json.RawMessage) ([]byte, error) {
+		return json.MarshalIndent(documents, "", "  ")
+	})}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &s.docs); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	return s, nil
+}
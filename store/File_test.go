@@ -0,0 +1,185 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Documents are compared as JSON, since the file store may reformat them
+func jsonEqual(t *testing.T, a, b string) bool {
+	t.Helper()
+	var x, y any
+	if err := json.Unmarshal([]byte(a), &x); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(b), &y); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// Only the store's own file should be in its directory after writing
+func checkNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("want just the store in %s, found %v", dir, names)
+	}
+}
+
+func TestFileMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	s, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Get("savedSettings"); got != "" {
+		t.Errorf("got %q from an empty store", got)
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("opening created the file: %v", err)
+	}
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "settings.json")
+	s, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Set("savedSettings", `{"schema_version":3,"settings":{}}`); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Set("appPreferences", `{"last_filter":"bank"}`); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Set("appPreferences", `{"last_filter":"mail"}`); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Remove("savedSettings"); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	checkNoTempFiles(t, dir)
+
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Get("savedSettings"); got != "" {
+		t.Errorf("removed document is %q", got)
+	}
+	if got := reopened.Get("appPreferences"); got == "" || !jsonEqual(t, got, `{"last_filter":"mail"}`) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestFileRejectsNonJSON(t *testing.T) {
+	s, err := OpenFile(filepath.Join(t.TempDir(), "settings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Set("savedSettings", "not JSON"); err == nil {
+		t.Error("no error")
+	}
+}
+
+func TestFileCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	if err := os.WriteFile(path, []byte(`{"savedSettings":`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFile(path); err == nil {
+		t.Error("no error")
+	}
+}
+
+// Changes are written together, after a pause, rather than one write per change
+func TestFileWritesInBackground(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	s, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.delay = 50 * time.Millisecond
+	for _, filter := range []string{"b", "ba", "ban", "bank"} {
+		if err = s.Set("appPreferences", `{"last_filter":"`+filter+`"}`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("written before the delay: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if reopened, err := OpenFile(path); err == nil && reopened.Get("appPreferences") != "" {
+			if got := reopened.Get("appPreferences"); !jsonEqual(t, got, `{"last_filter":"bank"}`) {
+				t.Errorf("got %q", got)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("never written")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// A failing write is reported once, not once per change, and is retried by the next flush
+func TestFileWriteFailureReportedOnce(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenFile(filepath.Join(dir, "missing", "settings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.delay = time.Millisecond
+	var mu sync.Mutex
+	var reported []error
+	s.SetOnError(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, err)
+	})
+	for i := 0; i < 5; i++ {
+		if err = s.Set("appPreferences", `{}`); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	mu.Lock()
+	if len(reported) != 1 {
+		t.Errorf("reported %d failures, want 1: %v", len(reported), reported)
+	}
+	mu.Unlock()
+
+	if err = os.Mkdir(filepath.Join(dir, "missing"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "missing", "settings.json")); errors.Is(err, os.ErrNotExist) {
+		t.Error("not written once the directory exists")
+	}
+}
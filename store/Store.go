@@ -0,0 +1,63 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+// Package store persists HM3k's documents (the saved settings and the app preferences).
+//
+// Documents are JSON, stored by name, in a plain JSON file or a file encrypted as a whole with a vault passphrase.
+// The app adds a store backed by Fyne's per-user preferences, so nothing here depends on Fyne.
+package store
+
+import (
+	"fmt"
+)
+
+// SettingsStore keeps named JSON documents. A document that doesn't exist is "".
+// The file stores are read when they're opened, and written in the background a moment after they change,
+// so Set only fails for a document that isn't JSON. Flush writes any changes straight away.
+type SettingsStore interface {
+	Get(name string) string
+	Set(name, value string) error
+	Remove(name string) error
+	Flush() error
+}
+
+// The kinds of store
+const (
+	TypePreferences   = "preferences"
+	TypeFile          = "file"
+	TypeEncryptedFile = "encrypted-file"
+)
+
+// Types lists the kinds of store, in the order they're offered in the UI
+var Types = []string{
+	TypePreferences,
+	TypeFile,
+	TypeEncryptedFile,
+}
+
+// Which store to use, and where. The file stores need a Path.
+type Config struct {
+	Type string `json:"type"`
+	Path string `json:"path,omitempty"`
+}
+
+// Check reports whether the configuration is complete
+func (c Config) Check() error {
+	switch c.Type {
+	case "", TypePreferences:
+		return nil
+	case TypeFile, TypeEncryptedFile:
+		if c.Path == "" {
+			return fmt.Errorf("the %s store needs a path", c.Type)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown store type: %s", c.Type)
+	}
+}
+
+// Encrypted reports whether the configuration is for a store that needs a passphrase to open
+func (c Config) Encrypted() bool {
+	return c.Type == TypeEncryptedFile
+}
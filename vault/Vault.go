@@ -0,0 +1,122 @@
+// Copyright (c) 2025 Neil Stephens. All rights reserved.
+// Use of this source code is governed by an MIT license that can be
+// found in the LICENSE file.
+
+// Package vault encrypts HM3k's saved settings at rest.
+//
+// A vault is AES-256-GCM, keyed by Argon2id of a vault passphrase. The salt and Argon2id
+// parameters are stored alongside the ciphertext, and each seal uses a fresh random nonce.
+// The key is derived once when the vault is created or unlocked, so sealing is cheap.
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	formatVersion = 1
+	saltSize      = 16
+	keySize       = 32 // AES-256
+)
+
+// Argon2id cost for new vaults. It's paid once per unlock.
+const (
+	defaultTime        = 3
+	defaultMemory      = 64 * 1024 // KiB
+	defaultParallelism = 1
+)
+
+// Returned when a vault can't be opened, which almost always means the passphrase is wrong
+var ErrDecrypt = errors.New("unable to decrypt vault (wrong passphrase?)")
+
+// The stored form of a vault
+type envelope struct {
+	Version     int    `json:"version"`
+	Salt        []byte `json:"salt"`
+	Time        uint32 `json:"time"`
+	Memory      uint32 `json:"memory"` // KiB
+	Parallelism uint8  `json:"parallelism"`
+	Nonce       []byte `json:"nonce"`
+	Ciphertext  []byte `json:"ciphertext"`
+}
+
+// An unlocked vault, which seals payloads with the key it was created or unlocked with
+type Vault struct {
+	header envelope // everything but the nonce and ciphertext
+	aead   cipher.AEAD
+}
+
+// Create makes a new vault with a random salt
+func Create(passphrase string) (*Vault, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return newVault(envelope{
+		Version:     formatVersion,
+		Salt:        salt,
+		Time:        defaultTime,
+		Memory:      defaultMemory,
+		Parallelism: defaultParallelism,
+	}, passphrase)
+}
+
+// Unlock opens a sealed vault, returning the vault (for sealing later changes) and its payload
+func Unlock(sealed []byte, passphrase string) (*Vault, []byte, error) {
+	var stored envelope
+	if err := json.Unmarshal(sealed, &stored); err != nil {
+		return nil, nil, fmt.Errorf("invalid vault: %v", err)
+	}
+	if stored.Version != formatVersion {
+		return nil, nil, fmt.Errorf("unsupported vault version %d", stored.Version)
+	}
+
+	header := stored
+	header.Nonce, header.Ciphertext = nil, nil
+	v, err := newVault(header, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(stored.Nonce) != v.aead.NonceSize() {
+		return nil, nil, ErrDecrypt
+	}
+	payload, err := v.aead.Open(nil, stored.Nonce, stored.Ciphertext, nil)
+	if err != nil {
+		return nil, nil, ErrDecrypt
+	}
+	return v, payload, nil
+}
+
+// Seal encrypts a payload with a fresh nonce, returning the stored form of the vault
+func (v *Vault) Seal(payload []byte) ([]byte, error) {
+	sealed := v.header
+	sealed.Nonce = make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(sealed.Nonce); err != nil {
+		return nil, err
+	}
+	sealed.Ciphertext = v.aead.Seal(nil, sealed.Nonce, payload, nil)
+	return json.Marshal(sealed)
+}
+
+func newVault(header envelope, passphrase string) (*Vault, error) {
+	if header.Time < 1 || header.Parallelism < 1 || len(header.Salt) == 0 {
+		return nil, fmt.Errorf("invalid vault parameters")
+	}
+	key := argon2.IDKey([]byte(passphrase), header.Salt, header.Time, header.Memory, header.Parallelism, keySize)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Vault{header: header, aead: aead}, nil
+}